@@ -0,0 +1,119 @@
+package data
+
+import "testing"
+
+// mixedBook holds bids and asks for two periods plus an empty entry
+var mixedBook = []BitfinexOffer{
+	{OfferID: 1, Period: 2, Rate: 0.0002, Amount: -500},
+	{OfferID: 2, Period: 2, Rate: 0.00025, Amount: -100},
+	{OfferID: 3, Period: 2, Rate: 0.0003, Amount: 800},
+	{OfferID: 4, Period: 2, Rate: 0.00028, Amount: 200},
+	{OfferID: 5, Period: 30, Rate: 0.0004, Amount: -1000},
+	{OfferID: 6, Period: 30, Rate: 0.0005, Amount: 300},
+	{OfferID: 7, Period: 2, Rate: 0.001, Amount: 0},
+}
+
+func TestBookSides(t *testing.T) {
+	tests := []struct {
+		amount      float64
+		wantLending bool
+		wantBid     bool
+	}{
+		{100, true, false},
+		{0.01, true, false},
+		{-100, false, true},
+		{0, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := isLendingOffer(tt.amount); got != tt.wantLending {
+			t.Errorf("isLendingOffer(%v) = %v, want %v", tt.amount, got, tt.wantLending)
+		}
+		if got := isBorrowingBid(tt.amount); got != tt.wantBid {
+			t.Errorf("isBorrowingBid(%v) = %v, want %v", tt.amount, got, tt.wantBid)
+		}
+	}
+}
+
+func TestBookSelectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		selectFunc func([]BitfinexOffer) (*BitfinexOffer, error)
+		book       []BitfinexOffer
+		wantErr    bool
+		wantID     int
+		wantPeriod int
+		wantRate   float64
+		wantAmount float64
+	}{
+		{"shortest period picks the best 2-day bid",
+			SelectHighestRateForShortestPeriod, mixedBook, false, 2, 2, 0.00025, -100},
+		{"shortest period ignores asks at shorter periods",
+			SelectHighestRateForShortestPeriod, append([]BitfinexOffer{{OfferID: 8, Period: 1, Rate: 0.01, Amount: 50}}, mixedBook[4:]...),
+			false, 5, 30, 0.0004, -1000},
+		{"shortest period without bids",
+			SelectHighestRateForShortestPeriod, []BitfinexOffer{mixedBook[2], mixedBook[6]}, true, 0, 0, 0, 0},
+		{"highest lending rate picks the best bid of any period",
+			func(b []BitfinexOffer) (*BitfinexOffer, error) { return SelectHighestLendingRate(b, 2) }, mixedBook, false, 5, 30, 0.0004, 1000},
+		{"highest lending rate honors the minimum period",
+			func(b []BitfinexOffer) (*BitfinexOffer, error) { return SelectHighestLendingRate(b, 60) }, mixedBook, true, 0, 0, 0, 0},
+		{"highest lending rate ignores an empty entry",
+			func(b []BitfinexOffer) (*BitfinexOffer, error) { return SelectHighestLendingRate(b, 2) }, mixedBook[6:], true, 0, 0, 0, 0},
+		{"lowest ask for 2 days",
+			func(b []BitfinexOffer) (*BitfinexOffer, error) { return SelectLowestAsk(b, 2) }, mixedBook, false, 4, 2, 0.00028, 200},
+		{"lowest ask for 30 days",
+			func(b []BitfinexOffer) (*BitfinexOffer, error) { return SelectLowestAsk(b, 30) }, mixedBook, false, 6, 30, 0.0005, 300},
+		{"no asks for the period",
+			func(b []BitfinexOffer) (*BitfinexOffer, error) { return SelectLowestAsk(b, 7) }, mixedBook, true, 0, 0, 0, 0},
+		{"lend side best rate",
+			func(b []BitfinexOffer) (*BitfinexOffer, error) {
+				return SelectOfferFromBook(b, OfferCriteria{Side: SideLend, Mode: ModeBestRate})
+			}, mixedBook, false, 5, 30, 0.0004, -1000},
+		{"borrow side best rate",
+			func(b []BitfinexOffer) (*BitfinexOffer, error) {
+				return SelectOfferFromBook(b, OfferCriteria{Side: SideBorrow, Mode: ModeBestRate})
+			}, mixedBook, false, 4, 2, 0.00028, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.selectFunc(tt.book)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.OfferID != tt.wantID || got.Period != tt.wantPeriod || got.Rate != tt.wantRate || got.Amount != tt.wantAmount {
+				t.Errorf("selected %+v, want ID %d, %d days at %v for %v", got, tt.wantID, tt.wantPeriod, tt.wantRate, tt.wantAmount)
+			}
+		})
+	}
+}
+
+func TestFindHighestRateForShortestPeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantErr  bool
+		wantID   int
+		wantRate float64
+	}{
+		{"bids and asks", `[[1,2,0.0002,-500],[3,2,0.0003,800],[2,2,0.00025,-100]]`, false, 2, 0.00025},
+		{"empty amount and short row are skipped", `[[7,2,0.001,0],[8,2,0.002],[1,2,0.0002,-500]]`, false, 1, 0.0002},
+		{"asks only", `[[3,2,0.0003,800]]`, true, 0, 0},
+		{"not json", `nope`, true, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindHighestRateForShortestPeriod([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (got.OfferID != tt.wantID || got.Rate != tt.wantRate) {
+				t.Errorf("selected %+v, want ID %d at %v", got, tt.wantID, tt.wantRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package data
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// doerFunc adapts a function to the Doer interface
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// cannedDoer answers every request with status and body
+func cannedDoer(status int, body string) Doer {
+	return doerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+		}, nil
+	})
+}
+
+// newTestClient returns a client whose requests are served by d, without
+// rate limiting, retries or log output
+func newTestClient(t *testing.T, d Doer, opts ...ClientOption) *Client {
+	t.Helper()
+	opts = append([]ClientOption{WithDoer(d), WithRateLimit(0, 0), WithLogger(util.NopLogger{})}, opts...)
+	c := NewClient("key", "secret", opts...)
+	c.MaxRetries = 0
+	return c
+}
@@ -2,28 +2,132 @@ package data
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"regexp"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gary/bitfinex-lending-bot/util.go"
 	"github.com/gorilla/websocket"
+	"golang.org/x/time/rate"
 )
 
 type Client struct {
-	APIKey     string
-	APISecret  string
-	HTTPClient *http.Client
-	BaseURL    string
+	APIKey      string
+	APISecret   string
+	HTTPClient  *http.Client // Used for REST requests unless WithDoer is set
+	BaseURL     string
+	WSPublicURL string // WebSocket endpoint for public channels
+	WSAuthURL   string // WebSocket endpoint for authenticated channels
+
+	MaxRetries   int           // Retries for transient errors (0 disables)
+	RetryBackoff time.Duration // Initial backoff, doubled on each retry
+
+	doer  Doer         // Executes REST requests in place of HTTPClient
+	rng   *lockedRand  // Source of all client randomness
+	nonce func() int64 // Source of request nonces
+
+	// Set by WithTimeout and WithTransport and applied to a copy of
+	// HTTPClient once all options have run
+	timeout    time.Duration
+	timeoutSet bool
+	transport  http.RoundTripper
+
+	rates *rateHistory // Recent rates per symbol, nil unless WithRateHistory is set
+
+	authLimiter   *rate.Limiter // Throttles authenticated endpoints
+	publicLimiter *rate.Limiter // Throttles public endpoints
+
+	logger         util.Logger           // Destination of all client log output
+	retryLog       *util.ThrottledLogger // Throttles retry messages so outages don't flood the log
+	retryLogWindow time.Duration         // Dedup window of retryLog
+}
+
+// Funding periods accepted by Bitfinex: any whole number of days in
+// [MinFundingPeriod, MaxFundingPeriod]
+const (
+	MinFundingPeriod = 2
+	MaxFundingPeriod = 120
+)
+
+// NearestValidPeriod snaps a computed period to the nearest period the
+// exchange accepts
+func NearestValidPeriod(p int) int {
+	if p < MinFundingPeriod {
+		return MinFundingPeriod
+	}
+	if p > MaxFundingPeriod {
+		return MaxFundingPeriod
+	}
+	return p
+}
+
+// minFundingAmounts holds the smallest offer amount per symbol in native
+// units. Bitfinex requires the equivalent of 150 USD, which only has a fixed
+// native amount for USD and stablecoins.
+var (
+	minFundingMu      sync.RWMutex
+	minFundingAmounts = map[string]float64{
+		"fUSD": 150,
+		"fUST": 150,
+	}
+)
+
+// MinFundingAmount returns the smallest amount Bitfinex accepts for an offer
+// on symbol, or 0 when it is unknown and left for the exchange to check
+func MinFundingAmount(symbol string) float64 {
+	minFundingMu.RLock()
+	defer minFundingMu.RUnlock()
+	return minFundingAmounts[symbol]
+}
+
+// SetMinFundingAmount overrides the minimum offer amount of symbol, e.g. to
+// add the current native minimum of a non-USD currency. An amount of 0
+// removes the local check.
+func SetMinFundingAmount(symbol string, amount float64) {
+	minFundingMu.Lock()
+	defer minFundingMu.Unlock()
+	if amount <= 0 {
+		delete(minFundingAmounts, symbol)
+		return
+	}
+	minFundingAmounts[symbol] = amount
+}
+
+// maxAmountDecimals is the number of decimals Bitfinex accepts in amounts
+const maxAmountDecimals = 8
+
+// currencyPrecisions holds the decimals offer amounts are sized in per
+// symbol. Fiat and stablecoins are sized in cents; other currencies, such as
+// fBTC, use every decimal Bitfinex accepts.
+var currencyPrecisions = map[string]int{
+	"fUSD": 2,
+	"fUST": 2,
+	"fEUR": 2,
+	"fGBP": 2,
+}
+
+// CurrencyPrecision returns the number of decimals offer amounts of symbol
+// are rounded to
+func CurrencyPrecision(symbol string) int {
+	if decimals, ok := currencyPrecisions[symbol]; ok {
+		return decimals
+	}
+	return maxAmountDecimals
 }
 
 // FundingOfferRequest represents a funding offer request
@@ -31,17 +135,106 @@ type FundingOfferRequest struct {
 	Type   string `json:"type"`   // Order type (LIMIT, FRRDELTAVAR, FRRDELTAFIX)
 	Symbol string `json:"symbol"` // Symbol for desired pair (fUSD, fBTC, etc.)
 	Amount string `json:"amount"` // Amount (positive for offer, negative for bid)
-	Rate   string `json:"rate"`   // Daily rate
+	Rate   string `json:"rate"`   // Daily rate, or the delta to FRR for FRRDELTA types
 	Period int    `json:"period"` // Time period of offer (2-120 days)
 	Flags  int    `json:"flags"`  // Optional flags
 }
 
+// Funding offer types
+const (
+	OfferTypeLimit       = "LIMIT"       // Fixed daily rate
+	OfferTypeFRRDeltaVar = "FRRDELTAVAR" // FRR plus Rate, following FRR while open
+	OfferTypeFRRDeltaFix = "FRRDELTAFIX" // FRR plus Rate, fixed when the offer is taken
+)
+
+// Validate checks the request locally so malformed offers fail before the
+// network round trip. An empty Type is treated as LIMIT. Amounts below
+// MinFundingAmount fail with an *OfferMinimumError.
+func (r FundingOfferRequest) Validate() error {
+	if r.Symbol == "" {
+		return fmt.Errorf("symbol cannot be empty")
+	}
+	if r.Amount == "" {
+		return fmt.Errorf("amount cannot be empty")
+	}
+	if r.Rate == "" {
+		return fmt.Errorf("rate cannot be empty")
+	}
+	if r.Period < MinFundingPeriod || r.Period > MaxFundingPeriod {
+		return fmt.Errorf("period must be between %d and %d days", MinFundingPeriod, MaxFundingPeriod)
+	}
+
+	amount, err := strconv.ParseFloat(r.Amount, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return fmt.Errorf("amount %q is not a valid number", r.Amount)
+	}
+	if amount == 0 {
+		return fmt.Errorf("amount cannot be zero")
+	}
+
+	rate, err := strconv.ParseFloat(r.Rate, 64)
+	if err != nil || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return fmt.Errorf("rate %q is not a valid number", r.Rate)
+	}
+
+	switch r.Type {
+	case "", OfferTypeLimit:
+		if rate <= 0 {
+			return fmt.Errorf("rate must be positive for %s offers, got %s", OfferTypeLimit, r.Rate)
+		}
+	case OfferTypeFRRDeltaVar, OfferTypeFRRDeltaFix:
+		// Rate is a delta to FRR and may be zero or negative
+	default:
+		return fmt.Errorf("unknown offer type %q", r.Type)
+	}
+
+	if min := MinFundingAmount(r.Symbol); min > 0 && math.Abs(amount) < min {
+		return &OfferMinimumError{
+			Minimum: min,
+			Err:     fmt.Errorf("amount %s is below the %s minimum", r.Amount, r.Symbol),
+		}
+	}
+
+	return nil
+}
+
+// FundingFlags is a bit set of Bitfinex order flags applied to a funding offer
+type FundingFlags int
+
+// Funding offer flags (see https://docs.bitfinex.com/docs/flag-values).
+// Bitfinex has no renew bit: auto-renew is configured per currency with
+// SetFundingAutoRenew.
+const (
+	// FundingFlagHidden keeps the offer out of the public funding book
+	FundingFlagHidden FundingFlags = 64
+	// FundingFlagClose is the Bitfinex close flag; leave it unset for
+	// no-close (rollover) behavior
+	FundingFlagClose FundingFlags = 512
+	// FundingFlagNoVarRates excludes variable-rate (FRR) demand from matching
+	FundingFlagNoVarRates FundingFlags = 524288
+)
+
+// With returns the flag set with flag added
+func (f FundingFlags) With(flag FundingFlags) FundingFlags {
+	return f | flag
+}
+
+// Without returns the flag set with flag removed
+func (f FundingFlags) Without(flag FundingFlags) FundingFlags {
+	return f &^ flag
+}
+
+// Has reports whether flag is set
+func (f FundingFlags) Has(flag FundingFlags) bool {
+	return f&flag == flag
+}
+
 // FundingOffer represents a funding offer response
 type FundingOffer struct {
-	ID             int       `json:"id"`          // Offer ID
-	Symbol         string    `json:"symbol"`      // The currency of the offer
-	CreatedAt      time.Time `json:"mts_create"`  // Creation timestamp
-	UpdatedAt      time.Time `json:"mts_update"`  // Update timestamp
+	ID             int       `json:"id"`     // Offer ID
+	Symbol         string    `json:"symbol"` // The currency of the offer
+	CreatedAt      time.Time // Creation timestamp
+	UpdatedAt      time.Time // Update timestamp
 	Amount         float64   `json:"amount"`      // Current amount
 	AmountOriginal float64   `json:"amount_orig"` // Original amount
 	Type           string    `json:"type"`        // Offer type
@@ -61,8 +254,12 @@ type BitfinexError struct {
 	RawBody    string
 }
 
+// FundingStat represents a funding statistics snapshot.
+// The Bitfinex stats endpoint does not report a period, so Period holds the
+// period that was requested (0 when stats cover all periods).
 type FundingStat struct {
 	Timestamp             int64   `json:"mts"`
+	Period                int     `json:"period"`
 	FRR                   float64 `json:"frr"`
 	AveragePeriod         float64 `json:"avg_period"`
 	FundingAmount         float64 `json:"funding_amount"`
@@ -88,6 +285,36 @@ type BitfinexOffer struct {
 	Amount  float64 // Amount (positive for ask, negative for bid)
 }
 
+// Funding book sign convention: Bitfinex reports asks, funds that lenders
+// offer, with a positive amount and bids, funds that borrowers request, with
+// a negative amount. A lender is filled immediately by matching a bid. An
+// entry with a zero amount is on neither side.
+const (
+	LendingOfferSign = 1  // Sign of an ask's amount
+	BorrowingBidSign = -1 // Sign of a bid's amount
+)
+
+// bookSign returns the sign of a book amount: 1, -1, or 0 for zero
+func bookSign(amount float64) int {
+	switch {
+	case amount > 0:
+		return 1
+	case amount < 0:
+		return -1
+	}
+	return 0
+}
+
+// isLendingOffer reports whether a book amount is an ask from a lender
+func isLendingOffer(amount float64) bool {
+	return bookSign(amount) == LendingOfferSign
+}
+
+// isBorrowingBid reports whether a book amount is a bid from a borrower
+func isBorrowingBid(amount float64) bool {
+	return bookSign(amount) == BorrowingBidSign
+}
+
 // TradeMessage represents a trade message
 type TradeMessage struct {
 	ID        int64
@@ -101,18 +328,49 @@ type TradeMessage struct {
 type TradeSubscription struct {
 	conn      *websocket.Conn
 	done      chan struct{}
+	closeOnce sync.Once
 	onMessage func(TradeMessage)
+	logger    util.Logger
 }
 
 // FundingCredit represents a funding credit
 type FundingCredit struct {
-	ID     int64
-	Status string
-	Amount float64
+	ID       int64
+	Symbol   string    // Symbol of the credit (fUSD, fUST, ...)
+	Status   string    // Credit status (ACTIVE, ...)
+	Amount   float64   // Amount lent
+	Rate     float64   // Daily rate (0 for FRR loans)
+	Period   int       // Period in days
+	OpenedAt time.Time // Time the credit was opened
+}
+
+// ExpiresAt returns when the credit is due back
+func (fc FundingCredit) ExpiresAt() time.Time {
+	return fc.OpenedAt.Add(time.Duration(fc.Period) * 24 * time.Hour)
+}
+
+// NewClient creates a client for the given API credentials. It panics if a
+// URL set through WithBaseURL, WithWSPublicURL or WithWSAuthURL is empty or
+// lacks a scheme and host, or if WithHTTPClient is given nil; use NewClientE
+// when the URLs come from user input.
+func NewClient(apiKey, apiSecret string, opts ...ClientOption) *Client {
+	c, err := NewClientE(apiKey, apiSecret, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("data.NewClient: %v", err))
+	}
+	return c
+}
+
+// NewClientWithOptions is NewClientE under the name of the functional
+// options constructor; both accept the same options
+func NewClientWithOptions(apiKey, apiSecret string, opts ...ClientOption) (*Client, error) {
+	return NewClientE(apiKey, apiSecret, opts...)
 }
 
-func NewClient(apiKey, apiSecret string) *Client {
-	return &Client{
+// NewClientE is like NewClient but returns an error for an invalid URL or a
+// nil WithHTTPClient instead of panicking
+func NewClientE(apiKey, apiSecret string, opts ...ClientOption) (*Client, error) {
+	c := &Client{
 		APIKey:    apiKey,
 		APISecret: apiSecret,
 		HTTPClient: &http.Client{
@@ -123,11 +381,189 @@ func NewClient(apiKey, apiSecret string) *Client {
 				IdleConnTimeout:     90 * time.Second,
 			},
 		},
-		BaseURL: "https://api.bitfinex.com",
+		BaseURL:      "https://api.bitfinex.com",
+		WSPublicURL:  "wss://api-pub.bitfinex.com/ws/2",
+		WSAuthURL:    "wss://api.bitfinex.com/ws/2",
+		MaxRetries:   3,
+		RetryBackoff: 500 * time.Millisecond,
+		rng:          newLockedRand(rand.NewSource(time.Now().UnixNano())),
+		nonce:        monotonicNonce,
+
+		authLimiter:    newPerMinuteLimiter(defaultRequestsPerMinute),
+		publicLimiter:  newPerMinuteLimiter(defaultRequestsPerMinute),
+		logger:         util.NewStdLogger(nil),
+		retryLogWindow: time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// Timeout and transport go on a copy so a client passed to
+	// WithHTTPClient is never modified, whatever the option order
+	if c.HTTPClient == nil {
+		return nil, fmt.Errorf("HTTP client cannot be nil")
+	}
+	if c.timeoutSet || c.transport != nil {
+		hc := *c.HTTPClient
+		if c.timeoutSet {
+			hc.Timeout = c.timeout
+		}
+		if c.transport != nil {
+			hc.Transport = c.transport
+		}
+		c.HTTPClient = &hc
+	}
+
+	c.retryLog = util.NewThrottledLogger(c.retryLogWindow)
+	c.retryLog.SetOutput(c.log().Warnf)
+
+	for _, u := range []*string{&c.BaseURL, &c.WSPublicURL, &c.WSAuthURL} {
+		normalized, err := normalizeBaseURL(*u)
+		if err != nil {
+			return nil, err
+		}
+		*u = normalized
+	}
+
+	return c, nil
+}
+
+// normalizeBaseURL validates a base URL and strips any trailing slashes so
+// that paths can be joined with a single "/"
+func normalizeBaseURL(raw string) (string, error) {
+	trimmed := strings.TrimRight(strings.TrimSpace(raw), "/")
+	if trimmed == "" {
+		return "", fmt.Errorf("base URL cannot be empty")
+	}
+
+	u, err := url.Parse(trimmed)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %w", raw, err)
 	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("base URL %q must include a scheme and host", raw)
+	}
+
+	return trimmed, nil
 }
 
+// SendRequest sends a request to the Bitfinex API. Authenticated (v2/auth/)
+// paths are signed; public paths are sent without credentials.
 func (c *Client) SendRequest(method, path string, body interface{}) ([]byte, error) {
+	return c.SendRequestContext(context.Background(), method, path, body)
+}
+
+// SendRequestContext is like SendRequest but honors ctx for cancellation.
+// Transient failures are retried with exponential backoff according to
+// MaxRetries and RetryBackoff.
+func (c *Client) SendRequestContext(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	path = normalizePath(path)
+	signed := isAuthPath(path)
+
+	// Bitfinex signs the path without a query string; authenticated
+	// endpoints take their parameters in the body
+	if signed && strings.Contains(path, "?") {
+		return nil, fmt.Errorf("authenticated path %q must not have a query string, pass parameters in the body", path)
+	}
+	return c.sendWithRetry(ctx, method, path, body, signed)
+}
+
+// SendPublicRequest sends an unsigned request to a public Bitfinex endpoint.
+// It needs no API key and uses no nonce.
+func (c *Client) SendPublicRequest(method, path string) ([]byte, error) {
+	return c.SendPublicRequestContext(context.Background(), method, path)
+}
+
+// SendPublicRequestContext is like SendPublicRequest but honors ctx for cancellation
+func (c *Client) SendPublicRequestContext(ctx context.Context, method, path string) ([]byte, error) {
+	path = normalizePath(path)
+	if isAuthPath(path) {
+		return nil, fmt.Errorf("path %q requires authentication, use SendRequest", path)
+	}
+	return c.sendWithRetry(ctx, method, path, nil, false)
+}
+
+// sendWithRetry sends a request, signed or not, retrying transient failures
+func (c *Client) sendWithRetry(ctx context.Context, method, path string, body interface{}, signed bool) ([]byte, error) {
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		respBody, err := c.sendRequestOnce(ctx, method, path, body, signed)
+		if err == nil || attempt >= c.MaxRetries || !isRetryable(ctx, method, path, err) {
+			return respBody, err
+		}
+
+		wait := backoff + c.Jitter(backoff/2)
+		c.logRetry("Retrying %s %s after error: %v", method, path, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryable reports whether a failed request may be safely retried.
+// Nonce and rate-limit rejections are never processed by the exchange, so
+// they are retried for any request; server and network errors are only
+// retried for idempotent reads.
+func isRetryable(ctx context.Context, method, path string, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	idempotent := method == http.MethodGet || strings.HasPrefix(path, "v2/auth/r/")
+
+	var bfxErr BitfinexError
+	if !errors.As(err, &bfxErr) {
+		// Transport failure: the request may or may not have been processed
+		return idempotent
+	}
+
+	switch {
+	case errors.Is(bfxErr, ErrNonceTooSmall), errors.Is(bfxErr, ErrRateLimited):
+		return true
+	case bfxErr.StatusCode >= 500:
+		return idempotent
+	}
+	return false
+}
+
+// isAuthPath reports whether path is an authenticated endpoint, which must
+// be signed. path must already be normalized.
+func isAuthPath(path string) bool {
+	return strings.HasPrefix(path, "v2/auth/")
+}
+
+// normalizePath strips leading slashes so "/v2/x" and "v2/x" produce the same
+// URL and signature; Bitfinex rejects signatures over "/api//v2/x"
+func normalizePath(path string) string {
+	return strings.TrimLeft(path, "/")
+}
+
+// signaturePayload builds the string signed for an authenticated REST
+// request. path must already be normalized.
+func signaturePayload(path, nonce, body string) string {
+	return "/api/" + path + nonce + body
+}
+
+// sign returns the hex-encoded HMAC-SHA384 of payload, as Bitfinex expects
+// for both REST and WebSocket authentication
+func sign(secret, payload string) string {
+	h := hmac.New(sha512.New384, []byte(secret))
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sendRequestOnce performs a single request, signing it when signed is set
+func (c *Client) sendRequestOnce(ctx context.Context, method, path string, body interface{}, signed bool) ([]byte, error) {
+	// Wait for the rate limiter before dispatching
+	if err := c.limiterFor(path).Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+
 	// Serialize request body
 	var bodyStr string
 	if body != nil {
@@ -138,32 +574,24 @@ func (c *Client) SendRequest(method, path string, body interface{}) ([]byte, err
 		bodyStr = string(jsonData)
 	}
 
-	// Generate nonce
-	nonce := strconv.FormatInt(time.Now().UnixNano()/1000000, 10)
-
-	// Create signature payload
-	signaturePayload := "/api/" + path + nonce + bodyStr
-
-	// Calculate signature
-	h := hmac.New(sha512.New384, []byte(c.APISecret))
-	h.Write([]byte(signaturePayload))
-	signature := hex.EncodeToString(h.Sum(nil))
-
 	// Create request
 	url := c.BaseURL + "/" + path
-	req, err := http.NewRequest(method, url, bytes.NewBufferString(bodyStr))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBufferString(bodyStr))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
-	// Set headers
+	// Set headers; public endpoints get no credentials and use no nonce
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("bfx-nonce", nonce)
-	req.Header.Set("bfx-apikey", c.APIKey)
-	req.Header.Set("bfx-signature", signature)
+	if signed {
+		nonce := c.Nonce()
+		req.Header.Set("bfx-nonce", nonce)
+		req.Header.Set("bfx-apikey", c.APIKey)
+		req.Header.Set("bfx-signature", sign(c.APISecret, signaturePayload(path, nonce, bodyStr)))
+	}
 
 	// Send request
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error sending request: %w", err)
 	}
@@ -186,10 +614,13 @@ func (c *Client) SendRequest(method, path string, body interface{}) ([]byte, err
 		}
 
 		if err == nil && len(errorResp) >= 3 {
-			if code, ok := errorResp[1].(string); ok {
+			switch code := errorResp[1].(type) {
+			case string:
 				bfxErr.ErrorCode = code
+			case float64:
+				bfxErr.ErrorCode = strconv.FormatFloat(code, 'f', -1, 64)
 			}
-			if msg, ok := errorResp[2].(string); ok {
+			if msg, ok := util.SafeString(errorResp[2]); ok {
 				bfxErr.Message = msg
 			}
 		} else {
@@ -207,95 +638,177 @@ func (e BitfinexError) Error() string {
 		e.ErrorCode, e.Message, e.StatusCode)
 }
 
+// SendBitfinexRequest sends a signed POST request to the production API.
+// requestBody must be empty or a JSON document. It is a thin wrapper around
+// a default Client so signing, nonces and error parsing live in one place.
+//
+// Deprecated: use Client.SendRequest, which honors the client's BaseURL,
+// retry policy and nonce source.
 func SendBitfinexRequest(apikey, apisecret, apiPath, requestBody string) ([]byte, error) {
-	// Generate nonce (millisecond timestamp)
-	nonce := strconv.FormatInt(time.Now().UnixNano()/1000000, 10)
-
-	// Create signature payload
-	signaturePayload := "/api/" + apiPath + nonce + requestBody
+	var body interface{}
+	if requestBody != "" {
+		body = json.RawMessage(requestBody)
+	}
+	client := NewClient(apikey, apisecret)
+	defer client.Close()
+	return client.SendRequest("POST", apiPath, body)
+}
 
-	// Calculate HMAC-SHA384 signature
-	h := hmac.New(sha512.New384, []byte(apisecret))
-	h.Write([]byte(signaturePayload))
-	signature := hex.EncodeToString(h.Sum(nil))
+// GetFundingStat retrieves the default window of funding statistics for a symbol
+func (c *Client) GetFundingStat(symbol string) ([]FundingStat, error) {
+	return c.GetFundingStatContext(context.Background(), symbol)
+}
 
-	// Create HTTP request
-	url := "https://api.bitfinex.com/" + apiPath
-	req, err := http.NewRequest("POST", url, bytes.NewBufferString(requestBody))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+// GetFundingStatContext is like GetFundingStat but honors ctx for cancellation
+func (c *Client) GetFundingStatContext(ctx context.Context, symbol string) ([]FundingStat, error) {
+	stats, err := c.GetFundingStatRangeContext(ctx, symbol, 0, 0, 0)
+	if err == nil && len(stats) > 0 {
+		c.recordRate(symbol, RatePoint{Time: time.UnixMilli(stats[0].Timestamp).UTC(), FRR: stats[0].FRR})
 	}
+	return stats, err
+}
 
-	// Set all necessary headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("bfx-nonce", nonce)
-	req.Header.Set("bfx-apikey", apikey)
-	req.Header.Set("bfx-signature", signature)
+// GetFundingStatRange retrieves funding statistics for a symbol between start
+// and end (millisecond timestamps), returning at most limit entries. Zero
+// values are omitted so Bitfinex applies its defaults.
+func (c *Client) GetFundingStatRange(symbol string, start, end int64, limit int) ([]FundingStat, error) {
+	return c.GetFundingStatRangeContext(context.Background(), symbol, start, end, limit)
+}
 
-	// Send request
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+// GetFundingStatRangeContext is like GetFundingStatRange but honors ctx for cancellation
+func (c *Client) GetFundingStatRangeContext(ctx context.Context, symbol string, start, end int64, limit int) ([]FundingStat, error) {
+	path := fundingStatsPath(symbol, start, end, limit)
+	respBody, err := c.SendPublicRequestContext(ctx, "GET", path)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, fmt.Errorf("failed to get funding statistics: %w", err)
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
+	// Parse the response into a slice of FundingStat
+	result, err := ParseFundingStats(respBody)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
+		return nil, fmt.Errorf("error parsing FundingStat: %w", err)
+	}
+	if err := c.checkSkippedRows("funding stat", len(result.Stats), result.SkippedRows); err != nil {
+		return nil, err
 	}
 
-	// Check for error response
-	if resp.StatusCode != http.StatusOK {
-		var errorResp []interface{}
-		if err := json.Unmarshal(respBody, &errorResp); err != nil {
-			return nil, fmt.Errorf("failed to parse error response: %v, raw response: %s", err, string(respBody))
-		} else if len(errorResp) >= 3 {
-			return nil, fmt.Errorf("API error [%v]: %v", errorResp[1], errorResp[2])
-		}
-		return nil, fmt.Errorf("unknown API error: %s", string(respBody))
+	return result.Stats, nil
+}
+
+// fundingStatsPath builds the stats endpoint path, adding only the non-zero
+// query parameters
+func fundingStatsPath(symbol string, start, end int64, limit int) string {
+	return withQuery(fmt.Sprintf("v2/funding/stats/%s/hist", symbol), historyQuery(start, end, limit))
+}
+
+// historyQuery builds the query of a public history endpoint, omitting zero
+// values
+func historyQuery(start, end int64, limit int) url.Values {
+	query := url.Values{}
+	if start > 0 {
+		query.Set("start", strconv.FormatInt(start, 10))
+	}
+	if end > 0 {
+		query.Set("end", strconv.FormatInt(end, 10))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
 	}
+	return query
+}
 
-	return respBody, nil
+// withQuery appends an encoded query to path when it is not empty
+func withQuery(path string, query url.Values) string {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path
 }
 
-func (c *Client) GetFundingStat(symbol string) ([]FundingStat, error) {
-	path := fmt.Sprintf("v2/funding/stats/%s/hist", symbol)
-	respBody, err := c.SendRequest("GET", path, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get funding statistics: %w", err)
+// GetFundingStatForPeriod retrieves funding statistics for a symbol, tagging
+// each entry with the requested period. The endpoint itself aggregates over
+// all periods; the period is threaded through so callers can key pricing by it.
+func (c *Client) GetFundingStatForPeriod(symbol string, period int, limit int) ([]FundingStat, error) {
+	return c.GetFundingStatForPeriodContext(context.Background(), symbol, period, limit)
+}
+
+// GetFundingStatForPeriodContext is like GetFundingStatForPeriod but honors ctx for cancellation
+func (c *Client) GetFundingStatForPeriodContext(ctx context.Context, symbol string, period int, limit int) ([]FundingStat, error) {
+	if period < MinFundingPeriod || period > MaxFundingPeriod {
+		return nil, fmt.Errorf("period must be between %d and %d days", MinFundingPeriod, MaxFundingPeriod)
 	}
 
-	// Parse the response into a slice of FundingStat
-	stats, err := parseFundingStats(respBody)
+	stats, err := c.GetFundingStatRangeContext(ctx, symbol, 0, 0, limit)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing FundingStat: %w", err)
+		return nil, err
+	}
+
+	for i := range stats {
+		stats[i].Period = period
 	}
 
 	return stats, nil
 }
 
-func parseFundingStats(data []byte) ([]FundingStat, error) {
+// SmoothedFRR returns the average FRR of the newest window entries of stats
+// (newest first, as returned by GetFundingStat). A window of 1 or less uses
+// the latest entry only; a window larger than stats averages all of them.
+// It returns 0 when stats is empty.
+func SmoothedFRR(stats []FundingStat, window int) float64 {
+	if len(stats) == 0 {
+		return 0
+	}
+	if window < 1 {
+		window = 1
+	}
+	if window > len(stats) {
+		window = len(stats)
+	}
+
+	var sum float64
+	for _, stat := range stats[:window] {
+		sum += stat.FRR
+	}
+	return sum / float64(window)
+}
+
+// ParseResult holds the funding stats parsed from a response and the rows
+// that had to be skipped. Rows being skipped, especially all of them, hints
+// that Bitfinex changed the array layout.
+type ParseResult struct {
+	Stats       []FundingStat
+	SkippedRows []ParseError
+}
+
+// ParseFundingStats parses a funding stats response. Only malformed JSON
+// fails; rows that do not match the expected layout are reported in
+// SkippedRows.
+func ParseFundingStats(data []byte) (ParseResult, error) {
 	var rawStats [][]interface{}
 	if err := json.Unmarshal(data, &rawStats); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %w", err)
+		return ParseResult{}, fmt.Errorf("error parsing JSON: %w", err)
 	}
 
-	stats := make([]FundingStat, 0, len(rawStats))
-	for _, raw := range rawStats {
+	result := ParseResult{Stats: make([]FundingStat, 0, len(rawStats))}
+	for i, raw := range rawStats {
 		if len(raw) < 12 {
+			result.SkippedRows = append(result.SkippedRows, newParseError(i, "length", raw))
 			continue
 		}
 
-		ts, ok1 := util.SafeFloat64(raw[0])
-		frr, ok2 := util.SafeFloat64(raw[3])
-		avgPeriod, ok3 := util.SafeFloat64(raw[4])
-		fundingAmt, ok4 := util.SafeFloat64(raw[7])
-		fundingUsed, ok5 := util.SafeFloat64(raw[8])
-		fundingBelow, ok6 := util.SafeFloat64(raw[11])
-
-		if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 {
+		ts, ok1 := util.FieldFloat(raw, 0)
+		frr, ok2 := util.FieldFloat(raw, 3)
+		avgPeriod, ok3 := util.FieldFloat(raw, 4)
+		fundingAmt, ok4 := util.FieldFloat(raw, 7)
+		fundingUsed, ok5 := util.FieldFloat(raw, 8)
+		fundingBelow, ok6 := util.FieldFloat(raw, 11)
+
+		if field := firstInvalid(
+			fieldCheck{"MTS", ok1}, fieldCheck{"FRR", ok2}, fieldCheck{"AVG_PERIOD", ok3},
+			fieldCheck{"FUNDING_AMOUNT", ok4}, fieldCheck{"FUNDING_AMOUNT_USED", ok5},
+			fieldCheck{"FUNDING_BELOW_THRESHOLD", ok6},
+		); field != "" {
+			result.SkippedRows = append(result.SkippedRows, newParseError(i, field, raw))
 			continue
 		}
 
@@ -307,21 +820,25 @@ func parseFundingStats(data []byte) ([]FundingStat, error) {
 			FundingAmountUsed:     fundingUsed,
 			FundingBelowThreshold: fundingBelow,
 		}
-		stats = append(stats, stat)
+		result.Stats = append(result.Stats, stat)
 	}
 
-	return stats, nil
+	return result, nil
 }
 
 func (c *Client) GetNewestTrades() ([]byte, error) {
+	return c.GetNewestTradesContext(context.Background())
+}
+
+// GetNewestTradesContext is like GetNewestTrades but honors ctx for cancellation
+func (c *Client) GetNewestTradesContext(ctx context.Context) ([]byte, error) {
 	path := "v2/trades/fUSD/hist?limit=125&sort=-1"
-	return c.SendRequest("GET", path, nil)
+	return c.SendPublicRequestContext(ctx, "GET", path)
 }
 
 // SubscribeToTrades subscribes to trade messages
 func (c *Client) SubscribeToTrades(symbol string, onMessage func(TradeMessage)) (*TradeSubscription, error) {
-	url := "wss://api-pub.bitfinex.com/ws/2"
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	conn, _, err := websocket.DefaultDialer.Dial(c.WSPublicURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("connection error: %w", err)
 	}
@@ -342,6 +859,7 @@ func (c *Client) SubscribeToTrades(symbol string, onMessage func(TradeMessage))
 		conn:      conn,
 		done:      make(chan struct{}),
 		onMessage: onMessage,
+		logger:    c.log(),
 	}
 
 	// Start listening goroutine
@@ -352,7 +870,7 @@ func (c *Client) SubscribeToTrades(symbol string, onMessage func(TradeMessage))
 
 // listen listens for WebSocket messages
 func (s *TradeSubscription) listen() {
-	defer s.conn.Close()
+	defer s.Close()
 
 	for {
 		select {
@@ -361,14 +879,19 @@ func (s *TradeSubscription) listen() {
 		default:
 			_, message, err := s.conn.ReadMessage()
 			if err != nil {
-				log.Printf("Error reading message: %v", err)
+				// A read failing because Close shut the connection is expected
+				select {
+				case <-s.done:
+				default:
+					s.logger.Errorf("Error reading message: %v", err)
+				}
 				return
 			}
 
 			// Parse message
 			var msg []interface{}
 			if err := json.Unmarshal(message, &msg); err != nil {
-				log.Printf("Error parsing message: %v", err)
+				s.logger.Errorf("Error parsing message: %v", err)
 				continue
 			}
 
@@ -379,11 +902,11 @@ func (s *TradeSubscription) listen() {
 					continue
 				}
 
-				id, _ := util.SafeInt64(data[0])
-				ts, _ := util.SafeInt64(data[1])
-				amount, _ := util.SafeFloat64(data[2])
-				rate, _ := util.SafeFloat64(data[3])
-				period, _ := util.SafeInt(data[4])
+				id, _ := util.FieldInt64(data, 0)
+				ts, _ := util.FieldInt64(data, 1)
+				amount, _ := util.FieldFloat(data, 2)
+				rate, _ := util.FieldFloat(data, 3)
+				period, _ := util.FieldInt(data, 4)
 
 				trade := TradeMessage{
 					ID:        id,
@@ -399,9 +922,14 @@ func (s *TradeSubscription) listen() {
 	}
 }
 
-// Close closes the subscription
+// Close closes the subscription. It is safe to call more than once and from
+// multiple goroutines.
 func (s *TradeSubscription) Close() {
-	close(s.done)
+	s.closeOnce.Do(func() {
+		close(s.done)
+		// Unblock a pending read in listen
+		s.conn.Close()
+	})
 }
 
 // Wallet represents a single wallet entry
@@ -415,81 +943,166 @@ type Wallet struct {
 	LastChangeMetadata map[string]interface{} // Last change metadata
 }
 
-// GetWallets retrieves all wallets and returns a map of funding wallet balances
-func (c *Client) GetWallets() (map[string]float64, error) {
-	respBody, err := c.SendRequest("POST", "v2/auth/r/wallets", nil)
+// GetWalletsDetailed retrieves every wallet of every type
+func (c *Client) GetWalletsDetailed() ([]Wallet, error) {
+	return c.GetWalletsDetailedContext(context.Background())
+}
+
+// GetWalletsDetailedContext is like GetWalletsDetailed but honors ctx for cancellation
+func (c *Client) GetWalletsDetailedContext(ctx context.Context) ([]Wallet, error) {
+	respBody, err := c.SendRequestContext(ctx, "POST", "v2/auth/r/wallets", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get wallets: %w", err)
 	}
 
-	// Parse JSON response
-	var wallets [][]interface{}
-	if err := json.Unmarshal(respBody, &wallets); err != nil {
-		return nil, fmt.Errorf("JSON parsing error: %w", err)
+	var rawWallets [][]interface{}
+	if err := json.Unmarshal(respBody, &rawWallets); err != nil {
+		return nil, fmt.Errorf("failed to parse wallets: %w", err)
 	}
 
-	// Create a map to store funding wallet balances
-	fundingBalances := make(map[string]float64)
+	wallets := make([]Wallet, 0, len(rawWallets))
+	for _, raw := range rawWallets {
+		if wallet, ok := parseWalletArray(raw); ok {
+			wallets = append(wallets, wallet)
+		}
+	}
+	return wallets, nil
+}
+
+// GetWallets retrieves all wallets and returns a map of funding wallet balances
+func (c *Client) GetWallets() (map[string]float64, error) {
+	return c.GetWalletsContext(context.Background())
+}
+
+// GetWalletsContext is like GetWallets but honors ctx for cancellation
+func (c *Client) GetWalletsContext(ctx context.Context) (map[string]float64, error) {
+	wallets, err := c.GetWalletsDetailedContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 
+	fundingBalances := make(map[string]float64)
 	for _, wallet := range wallets {
-		if len(wallet) >= 5 {
-			walletType := wallet[0].(string)
-			currency := wallet[1].(string)
-
-			// Only process funding type wallets
-			if walletType == "funding" {
-				availableBalance := wallet[4].(float64)
-				totalBalance := availableBalance
-				fundingBalances[currency] = totalBalance
-			}
+		if wallet.Type == "funding" {
+			fundingBalances[wallet.Currency] = wallet.AvailableBalance
 		}
 	}
-
 	return fundingBalances, nil
 }
 
 func (c *Client) GetRawBookHighest() ([]byte, error) {
+	return c.GetRawBookHighestContext(context.Background())
+}
+
+// GetRawBookHighestContext is like GetRawBookHighest but honors ctx for cancellation
+func (c *Client) GetRawBookHighestContext(ctx context.Context) ([]byte, error) {
 	path := "v2/book/fUSD/R0?len=100"
-	return c.SendRequest("GET", path, nil)
+	return c.SendPublicRequestContext(ctx, "GET", path)
 }
 
-// FindHighestRateForShortestPeriod parses Bitfinex API response data to find the highest rate for the shortest period
-func FindHighestRateForShortestPeriod(data []byte) (*BitfinexOffer, error) {
-	// Parse JSON data
-	var rawOffers [][]interface{}
-	err := json.Unmarshal(data, &rawOffers)
+// GetFundingBook retrieves the funding book for a symbol and returns its
+// entries sorted by rate, then period. precision is R0 for the raw book or
+// P0-P4 for aggregated price levels (where OfferID is 0). Amount keeps the
+// exchange sign: positive for asks (lenders), negative for bids (borrowers).
+func (c *Client) GetFundingBook(symbol string, precision string, length int) ([]BitfinexOffer, error) {
+	return c.GetFundingBookContext(context.Background(), symbol, precision, length)
+}
+
+// GetFundingBookContext is like GetFundingBook but honors ctx for cancellation
+func (c *Client) GetFundingBookContext(ctx context.Context, symbol string, precision string, length int) ([]BitfinexOffer, error) {
+	switch precision {
+	case "R0", "P0", "P1", "P2", "P3", "P4":
+	default:
+		return nil, fmt.Errorf("invalid book precision %q", precision)
+	}
+	if length <= 0 {
+		return nil, fmt.Errorf("length must be positive")
+	}
+
+	path := fmt.Sprintf("v2/book/%s/%s?len=%d", symbol, precision, length)
+	respBody, err := c.SendPublicRequestContext(ctx, "GET", path)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %v", err)
+		return nil, fmt.Errorf("failed to get funding book: %w", err)
 	}
 
-	// Convert raw data to structured BitfinexOffer objects
-	offers := make([]BitfinexOffer, 0, len(rawOffers))
+	offers, err := parseFundingBook(respBody, precision == "R0")
+	if err != nil {
+		return nil, fmt.Errorf("error parsing funding book: %w", err)
+	}
 
-	for _, rawOffer := range rawOffers {
-		if len(rawOffer) < 4 {
-			continue // Skip invalid format data
+	sort.Slice(offers, func(i, j int) bool {
+		if offers[i].Rate != offers[j].Rate {
+			return offers[i].Rate < offers[j].Rate
 		}
+		return offers[i].Period < offers[j].Period
+	})
+
+	return offers, nil
+}
 
-		offerID, okID := util.ToInt(rawOffer[0])
-		period, okPeriod := util.ToInt(rawOffer[1])
-		rate, okRate := util.ToFloat64(rawOffer[2])
-		amount, okAmount := util.ToFloat64(rawOffer[3])
+// parseFundingBook converts a funding book response into BitfinexOffers
+// Bitfinex API returns format:
+// raw books:        [[OFFER_ID, PERIOD, RATE, AMOUNT], ...]
+// aggregated books: [[RATE, PERIOD, COUNT, AMOUNT], ...]
+func parseFundingBook(data []byte, raw bool) ([]BitfinexOffer, error) {
+	var rows [][]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	}
 
-		if !okID || !okPeriod || !okRate || !okAmount {
+	offers := make([]BitfinexOffer, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 4 {
 			continue
 		}
 
-		// Only consider ask orders (positive amount)
-		if amount >= 0 {
+		period, okPeriod := util.FieldInt(row, 1)
+		amount, okAmount := util.FieldFloat(row, 3)
+
+		var offer BitfinexOffer
+		var okFirst, okRate bool
+		if raw {
+			offer.OfferID, okFirst = util.FieldInt(row, 0)
+			offer.Rate, okRate = util.FieldFloat(row, 2)
+		} else {
+			offer.Rate, okRate = util.FieldFloat(row, 0)
+			_, okFirst = util.FieldInt(row, 2)
+		}
+
+		if !okFirst || !okPeriod || !okRate || !okAmount {
 			continue
 		}
 
-		offers = append(offers, BitfinexOffer{
-			OfferID: offerID,
-			Period:  period,
-			Rate:    rate,
-			Amount:  amount,
-		})
+		offer.Period = period
+		offer.Amount = amount
+		offers = append(offers, offer)
+	}
+
+	return offers, nil
+}
+
+// FindHighestRateForShortestPeriod parses Bitfinex API response data to find the highest rate for the shortest period
+func FindHighestRateForShortestPeriod(data []byte) (*BitfinexOffer, error) {
+	// Parse JSON data
+	offers, err := parseFundingBook(data, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return SelectHighestRateForShortestPeriod(offers)
+}
+
+// SelectHighestRateForShortestPeriod finds the highest bid rate for the
+// shortest period among raw book entries, i.e. the best rate a lender can be
+// filled at right away
+func SelectHighestRateForShortestPeriod(book []BitfinexOffer) (*BitfinexOffer, error) {
+	offers := make([]BitfinexOffer, 0, len(book))
+	for _, offer := range book {
+		// Only consider borrower bids (negative amount)
+		if !isBorrowingBid(offer.Amount) {
+			continue
+		}
+		offers = append(offers, offer)
 	}
 
 	if len(offers) == 0 {
@@ -518,46 +1131,131 @@ func FindHighestRateForShortestPeriod(data []byte) (*BitfinexOffer, error) {
 	return &bestOffer, nil
 }
 
-// FindHighestLendingRate finds the highest lending rate that meets the minimum period requirement
-func FindHighestLendingRate(data []byte, minPeriod int) (*BitfinexOffer, error) {
-	var rawOffers [][]interface{}
-	err := json.Unmarshal(data, &rawOffers)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %v", err)
+// BestPeriodByYield groups the bids in book by period and returns the best
+// bid of the period that pays the most. Daily rates annualize the same way
+// for every period, so yields compare directly on rate. A period longer than
+// the shortest one is only chosen when its rate beats the shortest period's
+// rate by at least minPremium (e.g. 0.1 for 10%), compensating for locking
+// funds up longer.
+func BestPeriodByYield(book []BitfinexOffer, minPremium float64) (*BitfinexOffer, error) {
+	// Highest bid per period
+	best := make(map[int]BitfinexOffer)
+	for _, offer := range book {
+		if !isBorrowingBid(offer.Amount) {
+			continue
+		}
+		if current, ok := best[offer.Period]; !ok || offer.Rate > current.Rate {
+			best[offer.Period] = offer
+		}
+	}
+
+	if len(best) == 0 {
+		return nil, fmt.Errorf("no valid offers found")
 	}
 
-	offers := make([]BitfinexOffer, 0, len(rawOffers))
+	periods := make([]int, 0, len(best))
+	for period := range best {
+		periods = append(periods, period)
+	}
+	sort.Ints(periods)
 
-	for _, rawOffer := range rawOffers {
-		if len(rawOffer) < 4 {
-			continue
+	chosen := best[periods[0]]
+	threshold := chosen.Rate * (1 + minPremium)
+	for _, period := range periods[1:] {
+		if offer := best[period]; offer.Rate >= threshold && offer.Rate > chosen.Rate {
+			chosen = offer
 		}
+	}
 
-		offerID, okID := util.ToInt(rawOffer[0])
-		period, okPeriod := util.ToInt(rawOffer[1])
-		rate, okRate := util.ToFloat64(rawOffer[2])
-		amount, okAmount := util.ToFloat64(rawOffer[3])
+	return &chosen, nil
+}
 
-		if !okID || !okPeriod || !okRate || !okAmount {
+// SelectLowestAsk returns the lender ask (positive amount) with the lowest
+// rate for period, i.e. the front of the queue a new offer for that period
+// joins
+func SelectLowestAsk(book []BitfinexOffer, period int) (*BitfinexOffer, error) {
+	var best *BitfinexOffer
+	for i, offer := range book {
+		if !isLendingOffer(offer.Amount) || offer.Period != period {
 			continue
 		}
-
-		// Only consider borrowing orders (negative amount)
-		if amount >= 0 {
-			continue
+		if best == nil || offer.Rate < best.Rate {
+			best = &book[i]
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no asks found for %d days", period)
+	}
+	result := *best
+	return &result, nil
+}
+
+// WeightedAverageRate returns the amount-weighted average rate of the asks
+// (positive amounts) in book, walking them from the lowest rate up until
+// depthAmount has been accumulated. complete is false when the book holds
+// less than depthAmount, in which case the average covers every ask.
+func WeightedAverageRate(book []BitfinexOffer, depthAmount float64) (rate float64, complete bool, err error) {
+	if depthAmount <= 0 {
+		return 0, false, fmt.Errorf("depth amount must be positive")
+	}
+
+	asks := make([]BitfinexOffer, 0, len(book))
+	for _, offer := range book {
+		if isLendingOffer(offer.Amount) {
+			asks = append(asks, offer)
+		}
+	}
+	if len(asks) == 0 {
+		return 0, false, fmt.Errorf("no asks in book")
+	}
+
+	sort.SliceStable(asks, func(i, j int) bool {
+		return asks[i].Rate < asks[j].Rate
+	})
+
+	var filled, weighted float64
+	for _, ask := range asks {
+		take := math.Min(ask.Amount, depthAmount-filled)
+		filled += take
+		weighted += take * ask.Rate
+		if filled >= depthAmount {
+			return weighted / filled, true, nil
+		}
+	}
+
+	return weighted / filled, false, nil
+}
+
+// FindHighestLendingRate finds the highest lending rate that meets the minimum period requirement
+func FindHighestLendingRate(data []byte, minPeriod int) (*BitfinexOffer, error) {
+	offers, err := parseFundingBook(data, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return SelectHighestLendingRate(offers, minPeriod)
+}
+
+// SelectHighestLendingRate returns the highest-rate borrowing order in a raw
+// book whose period is at least minPeriod, preferring shorter periods on
+// ties. Amount is returned as a positive value.
+func SelectHighestLendingRate(book []BitfinexOffer, minPeriod int) (*BitfinexOffer, error) {
+	offers := make([]BitfinexOffer, 0, len(book))
+
+	for _, offer := range book {
+		// Only consider borrower bids (negative amount)
+		if !isBorrowingBid(offer.Amount) {
+			continue
 		}
 
 		// Filter out orders that don't meet minimum period requirement
-		if period < minPeriod {
+		if offer.Period < minPeriod {
 			continue
 		}
 
-		offers = append(offers, BitfinexOffer{
-			OfferID: offerID,
-			Period:  period,
-			Rate:    rate,
-			Amount:  math.Abs(amount), // Convert to positive value for easier understanding
-		})
+		offer.Amount = math.Abs(offer.Amount) // Convert to positive value for easier understanding
+		offers = append(offers, offer)
 	}
 
 	if len(offers) == 0 {
@@ -575,81 +1273,178 @@ func FindHighestLendingRate(data []byte, minPeriod int) (*BitfinexOffer, error)
 		}
 	}
 
-	// Output result
-	fmt.Printf("Found highest rate lending offer:\n")
-	fmt.Printf("Rate: %.6f%% (%.6f decimal)\n", highestRateOffer.Rate*100, highestRateOffer.Rate)
-	fmt.Printf("Period: %d days\n", highestRateOffer.Period)
-	fmt.Printf("Amount: %.2f USD\n", highestRateOffer.Amount)
-	fmt.Printf("Order ID: %d\n", highestRateOffer.OfferID)
-
 	return &highestRateOffer, nil
 }
 
-func (c *Client) GetTotalWalletBalance() (float64, float64, error) {
-	respBody, err := c.SendRequest("POST", "v2/auth/r/wallets", nil)
+// BookSide selects which side of the funding book SelectOffer picks from
+type BookSide string
+
+const (
+	// SideLend picks from the borrower bids (negative amounts) a lender can
+	// be filled by
+	SideLend BookSide = "lend"
+
+	// SideBorrow picks from the lender asks (positive amounts) a borrower
+	// can take
+	SideBorrow BookSide = "borrow"
+)
+
+// SelectionMode is how SelectOffer ranks the eligible book entries
+type SelectionMode string
+
+const (
+	// ModeBestRate picks the best rate for the side: the highest bid when
+	// lending, the lowest ask when borrowing. Ties go to the shorter period.
+	ModeBestRate SelectionMode = "best_rate"
+
+	// ModeShortestPeriod picks the best rate among the shortest period
+	ModeShortestPeriod SelectionMode = "shortest_period"
+
+	// ModeBestYield picks like BestPeriodByYield; it only applies to SideLend
+	ModeBestYield SelectionMode = "best_yield"
+)
+
+// OfferCriteria configures SelectOffer
+type OfferCriteria struct {
+	Side       BookSide
+	MinPeriod  int           // Shortest period considered (0 for no limit)
+	MaxPeriod  int           // Longest period considered (0 for no limit)
+	Mode       SelectionMode // Ranking of the eligible entries
+	MinPremium float64       // Term premium required by ModeBestYield
+}
+
+// SelectOffer parses a raw funding book response, e.g. a dump captured from
+// GetRawBookHighest, and returns the entry matching criteria. Amount keeps
+// the book's sign.
+func SelectOffer(data []byte, criteria OfferCriteria) (*BitfinexOffer, error) {
+	book, err := parseFundingBook(data, true)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get wallets: %w", err)
+		return nil, err
 	}
+	return SelectOfferFromBook(book, criteria)
+}
 
-	var wallets [][]interface{}
-	if err := json.Unmarshal(respBody, &wallets); err != nil {
-		return 0, 0, fmt.Errorf("failed to parse wallets: %w", err)
+// SelectOfferFromBook is like SelectOffer for an already parsed book
+func SelectOfferFromBook(book []BitfinexOffer, criteria OfferCriteria) (*BitfinexOffer, error) {
+	var better func(a, b float64) bool
+	switch criteria.Side {
+	case SideLend:
+		better = func(a, b float64) bool { return a > b }
+	case SideBorrow:
+		better = func(a, b float64) bool { return a < b }
+	default:
+		return nil, fmt.Errorf("unknown book side %q", criteria.Side)
+	}
+
+	eligible := make([]BitfinexOffer, 0, len(book))
+	for _, offer := range book {
+		if criteria.Side == SideLend && !isBorrowingBid(offer.Amount) ||
+			criteria.Side == SideBorrow && !isLendingOffer(offer.Amount) {
+			continue
+		}
+		if offer.Period < criteria.MinPeriod || criteria.MaxPeriod > 0 && offer.Period > criteria.MaxPeriod {
+			continue
+		}
+		eligible = append(eligible, offer)
+	}
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("no offers match the criteria")
 	}
 
-	var usdBalance float64
-	var ustBalance float64
+	switch criteria.Mode {
+	case ModeBestRate:
+		chosen := eligible[0]
+		for _, offer := range eligible[1:] {
+			if better(offer.Rate, chosen.Rate) || offer.Rate == chosen.Rate && offer.Period < chosen.Period {
+				chosen = offer
+			}
+		}
+		return &chosen, nil
 
-	for _, wallet := range wallets {
-		if len(wallet) >= 3 {
-			walletType := wallet[0].(string)
-			currency := wallet[1].(string)
-			balance := wallet[2].(float64)
-
-			if walletType == "funding" {
-				switch currency {
-				case "USD":
-					usdBalance = balance
-				case "UST":
-					ustBalance = balance
-				}
+	case ModeShortestPeriod:
+		chosen := eligible[0]
+		for _, offer := range eligible[1:] {
+			if offer.Period < chosen.Period || offer.Period == chosen.Period && better(offer.Rate, chosen.Rate) {
+				chosen = offer
 			}
 		}
+		return &chosen, nil
+
+	case ModeBestYield:
+		if criteria.Side != SideLend {
+			return nil, fmt.Errorf("best yield selection only applies to lending")
+		}
+		return BestPeriodByYield(eligible, criteria.MinPremium)
 	}
+	return nil, fmt.Errorf("unknown selection mode %q", criteria.Mode)
+}
 
-	return usdBalance, ustBalance, nil
+// GetTotalWalletBalance returns the total USD and UST funding wallet balances
+func (c *Client) GetTotalWalletBalance() (float64, float64, error) {
+	return c.GetTotalWalletBalanceContext(context.Background())
 }
 
-// SubmitFundingOffer submits a new funding offer and returns the offer details
-func (c *Client) SubmitFundingOffer(offer FundingOfferRequest) (*FundingOffer, error) {
-	// Validate required parameters
-	if offer.Symbol == "" {
-		return nil, fmt.Errorf("symbol cannot be empty")
+// GetTotalWalletBalanceContext is like GetTotalWalletBalance but honors ctx for cancellation
+func (c *Client) GetTotalWalletBalanceContext(ctx context.Context) (float64, float64, error) {
+	balances, err := c.GetFundingBalancesContext(ctx)
+	if err != nil {
+		return 0, 0, err
 	}
-	if offer.Amount == "" {
-		return nil, fmt.Errorf("amount cannot be empty")
+	return balances["USD"], balances["UST"], nil
+}
+
+// GetFundingBalances returns the total balance of every funding wallet,
+// keyed by currency
+func (c *Client) GetFundingBalances() (map[string]float64, error) {
+	return c.GetFundingBalancesContext(context.Background())
+}
+
+// GetFundingBalancesContext is like GetFundingBalances but honors ctx for cancellation
+func (c *Client) GetFundingBalancesContext(ctx context.Context) (map[string]float64, error) {
+	wallets, err := c.GetWalletsDetailedContext(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if offer.Rate == "" {
-		return nil, fmt.Errorf("rate cannot be empty")
+
+	balances := make(map[string]float64)
+	for _, wallet := range wallets {
+		if wallet.Type == "funding" {
+			balances[wallet.Currency] = wallet.Balance
+		}
 	}
-	if offer.Period < 2 || offer.Period > 120 {
-		return nil, fmt.Errorf("period must be between 2 and 120 days")
+	return balances, nil
+}
+
+// SubmitFundingOffer submits a new funding offer and returns the offer details
+func (c *Client) SubmitFundingOffer(offer FundingOfferRequest) (*FundingOffer, error) {
+	return c.SubmitFundingOfferContext(context.Background(), offer)
+}
+
+// SubmitFundingOfferContext is like SubmitFundingOffer but honors ctx for cancellation
+func (c *Client) SubmitFundingOfferContext(ctx context.Context, offer FundingOfferRequest) (*FundingOffer, error) {
+	// Validate required parameters
+	if err := offer.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid funding offer: %w", err)
 	}
 
 	// If type is not specified, default to LIMIT
 	if offer.Type == "" {
-		offer.Type = "LIMIT"
+		offer.Type = OfferTypeLimit
 	}
 
 	// Send request to Bitfinex API
-	respBody, err := c.SendRequest("POST", "v2/auth/w/funding/offer/submit", offer)
+	respBody, err := c.SendRequestContext(ctx, "POST", "v2/auth/w/funding/offer/submit", offer)
+	if minErr := asOfferMinimumError(err); minErr != nil {
+		return nil, minErr
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to submit funding offer: %v", err)
+		return nil, fmt.Errorf("failed to submit funding offer: %w", err)
 	}
 
 	// Parse the response
 	var response []interface{}
 	if err := json.Unmarshal(respBody, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	// Check if the response has the expected format
@@ -657,6 +1452,20 @@ func (c *Client) SubmitFundingOffer(offer FundingOfferRequest) (*FundingOffer, e
 		return nil, fmt.Errorf("invalid response format")
 	}
 
+	// Bitfinex can reject a submission with HTTP 200 and an error status
+	if ack := parseNotification(response); ack.Status != "SUCCESS" {
+		bfxErr := BitfinexError{
+			StatusCode: http.StatusOK,
+			ErrorCode:  ack.Code,
+			Message:    ack.Text,
+			RawBody:    string(respBody),
+		}
+		if minErr := asOfferMinimumError(bfxErr); minErr != nil {
+			return nil, minErr
+		}
+		return nil, fmt.Errorf("failed to submit funding offer: %w", bfxErr)
+	}
+
 	// Extract the offer data
 	offerData, ok := response[4].([]interface{})
 	if !ok || len(offerData) < 20 {
@@ -664,48 +1473,909 @@ func (c *Client) SubmitFundingOffer(offer FundingOfferRequest) (*FundingOffer, e
 	}
 
 	// Create and populate the FundingOffer
-	result := &FundingOffer{
-		ID:             int(offerData[0].(float64)),
-		Symbol:         offerData[1].(string),
-		CreatedAt:      time.Unix(0, int64(offerData[2].(float64))*int64(time.Millisecond)),
-		UpdatedAt:      time.Unix(0, int64(offerData[3].(float64))*int64(time.Millisecond)),
-		Amount:         offerData[4].(float64),
-		AmountOriginal: offerData[5].(float64),
-		Type:           offerData[6].(string),
-		Flags:          int(offerData[9].(float64)),
-		Status:         offerData[10].(string),
-		Rate:           offerData[14].(float64),
-		Period:         int(offerData[15].(float64)),
-		Notify:         offerData[16].(bool),
-		Hidden:         int(offerData[17].(float64)),
-		Renew:          offerData[19].(bool),
+	result, ok := parseFundingOfferArray(offerData)
+	if !ok {
+		return nil, fmt.Errorf("invalid offer data format")
 	}
 
-	return result, nil
+	return &result, nil
+}
+
+// GetActiveFundingOffers retrieves the currently active funding offers for a
+// symbol, or for all symbols when symbol is empty
+func (c *Client) GetActiveFundingOffers(symbol string) ([]FundingOffer, error) {
+	return c.GetActiveFundingOffersContext(context.Background(), symbol)
+}
+
+// GetActiveFundingOffersContext is like GetActiveFundingOffers but honors ctx for cancellation
+func (c *Client) GetActiveFundingOffersContext(ctx context.Context, symbol string) ([]FundingOffer, error) {
+	path := "v2/auth/r/funding/offers"
+	if symbol != "" {
+		path += "/" + symbol
+	}
+	respBody, err := c.SendRequestContext(ctx, "POST", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active funding offers: %w", err)
+	}
+
+	var rawOffers [][]interface{}
+	if err := json.Unmarshal(respBody, &rawOffers); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	offers, skipped := parseFundingOfferList(rawOffers)
+	if err := c.checkSkippedRows("funding offer", len(offers), skipped); err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+// FindOfferByParams looks for an active offer of symbol with the given
+// original amount, rate and period, e.g. to learn whether a submission that
+// timed out reached the exchange before retrying it. When several offers
+// match, the newest is returned; found is false when none does.
+func (c *Client) FindOfferByParams(symbol string, amount, rate float64, period int) (*FundingOffer, bool, error) {
+	return c.FindOfferByParamsContext(context.Background(), symbol, amount, rate, period)
+}
+
+// FindOfferByParamsContext is like FindOfferByParams but honors ctx for cancellation
+func (c *Client) FindOfferByParamsContext(ctx context.Context, symbol string, amount, rate float64, period int) (*FundingOffer, bool, error) {
+	offers, err := c.GetActiveFundingOffersContext(ctx, symbol)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var match *FundingOffer
+	for i := range offers {
+		offer := &offers[i]
+		if offer.Period != period ||
+			math.Abs(offer.AmountOriginal-amount) > offerParamTolerance ||
+			math.Abs(offer.Rate-rate) > offerParamTolerance {
+			continue
+		}
+		if match == nil || offer.CreatedAt.After(match.CreatedAt) {
+			match = offer
+		}
+	}
+	return match, match != nil, nil
+}
+
+// offerParamTolerance absorbs float noise when comparing offer amounts and
+// rates, which are submitted with at most 8 decimals
+const offerParamTolerance = 1e-9
+
+// GetFundingOfferHistory retrieves closed funding offers for a symbol, or for
+// every symbol when symbol is empty. Status holds the final state, e.g.
+// "EXECUTED at 0.02% (500.0)" or "CANCELED". start and end are millisecond
+// timestamps and limit caps the number of results; zero values leave the
+// exchange defaults.
+func (c *Client) GetFundingOfferHistory(symbol string, start, end int64, limit int) ([]FundingOffer, error) {
+	return c.GetFundingOfferHistoryContext(context.Background(), symbol, start, end, limit)
+}
+
+// GetFundingOfferHistoryContext is like GetFundingOfferHistory but honors ctx for cancellation
+func (c *Client) GetFundingOfferHistoryContext(ctx context.Context, symbol string, start, end int64, limit int) ([]FundingOffer, error) {
+	path := "v2/auth/r/funding/offers"
+	if symbol != "" {
+		path += "/" + symbol
+	}
+	path += "/hist"
+
+	respBody, err := c.SendRequestContext(ctx, "POST", path, historyParams(start, end, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funding offer history: %w", err)
+	}
+
+	var rawOffers [][]interface{}
+	if err := json.Unmarshal(respBody, &rawOffers); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	offers, skipped := parseFundingOfferList(rawOffers)
+	if err := c.checkSkippedRows("funding offer", len(offers), skipped); err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+// UpdateFundingOfferRate changes the rate (or FRR delta offset for
+// FRRDELTAVAR offers) of an active offer. Bitfinex has no endpoint for
+// updating funding offers in place, so the offer is cancelled and resubmitted
+// with the same type, amount, period and flags; the returned offer carries
+// the new ID and the queue position is lost. newRate is validated against
+// the offer type before anything is cancelled, so a zero delta is accepted
+// for FRRDELTA offers only.
+//
+// The offer is cancelled before resubmitting because its amount stays
+// locked while it is open; use ReplaceFundingOffer when the free balance
+// covers a second offer. If the resubmission fails the funds are left
+// unoffered and the cancelled offer is returned along with the error.
+func (c *Client) UpdateFundingOfferRate(offerID int, newRate float64) (*FundingOffer, error) {
+	return c.UpdateFundingOfferRateContext(context.Background(), offerID, newRate)
+}
+
+// UpdateFundingOfferRateContext is like UpdateFundingOfferRate but honors ctx for cancellation
+func (c *Client) UpdateFundingOfferRateContext(ctx context.Context, offerID int, newRate float64) (*FundingOffer, error) {
+	// Find the offer to copy its parameters
+	var current *FundingOffer
+	offers, err := c.GetActiveFundingOffersContext(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to update funding offer: %w", err)
+	}
+	for i := range offers {
+		if offers[i].ID == offerID {
+			current = &offers[i]
+			break
+		}
+	}
+	if current == nil {
+		return nil, fmt.Errorf("funding offer %d not found", offerID)
+	}
+
+	req := FundingOfferRequest{
+		Type:   current.Type,
+		Symbol: current.Symbol,
+		Amount: strconv.FormatFloat(current.Amount, 'f', -1, 64),
+		Rate:   strconv.FormatFloat(newRate, 'f', -1, 64),
+		Period: current.Period,
+		Flags:  current.Flags,
+	}
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to update funding offer %d: %w", offerID, err)
+	}
+
+	cancelled, err := c.CancelFundingOfferContext(ctx, offerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update funding offer: %w", err)
+	}
+
+	placed, err := c.SubmitFundingOfferContext(ctx, req)
+	if err != nil {
+		return cancelled, fmt.Errorf("funding offer %d cancelled but resubmitting it failed: %w", offerID, err)
+	}
+	return placed, nil
+}
+
+// ReplaceFundingOffer replaces an active offer with newOffer without leaving
+// the funds unoffered in between: newOffer is submitted first and oldID is
+// cancelled only once it is accepted. If the cancel fails the new offer is
+// cancelled again so the two never stay open together. The free balance must
+// cover newOffer while the old offer is still open; use
+// UpdateFundingOfferRate when it cannot.
+func (c *Client) ReplaceFundingOffer(oldID int, newOffer FundingOfferRequest) (*FundingOffer, error) {
+	return c.ReplaceFundingOfferContext(context.Background(), oldID, newOffer)
+}
+
+// ReplaceFundingOfferContext is like ReplaceFundingOffer but honors ctx for cancellation
+func (c *Client) ReplaceFundingOfferContext(ctx context.Context, oldID int, newOffer FundingOfferRequest) (*FundingOffer, error) {
+	placed, err := c.SubmitFundingOfferContext(ctx, newOffer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace funding offer %d: %w", oldID, err)
+	}
+
+	if _, err := c.CancelFundingOfferContext(ctx, oldID); err != nil {
+		if _, rollbackErr := c.CancelFundingOfferContext(ctx, placed.ID); rollbackErr != nil {
+			return nil, fmt.Errorf("failed to replace funding offer %d: %w; rolling back new offer %d also failed: %w",
+				oldID, err, placed.ID, rollbackErr)
+		}
+		return nil, fmt.Errorf("failed to replace funding offer %d, new offer rolled back: %w", oldID, err)
+	}
+
+	return placed, nil
+}
+
+// parseMillisTimestamp converts a Bitfinex millisecond timestamp into a UTC time
+func parseMillisTimestamp(v interface{}) (time.Time, bool) {
+	ms, ok := util.SafeInt64(v)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms).UTC(), true
+}
+
+// parseFundingOfferArray converts a Bitfinex funding offer array into a FundingOffer
+// Bitfinex API returns format:
+// [ID, SYMBOL, MTS_CREATE, MTS_UPDATE, AMOUNT, AMOUNT_ORIG, TYPE, _, _, FLAGS, STATUS, _, _, _, RATE, PERIOD, NOTIFY, HIDDEN, _, RENEW, ...]
+func parseFundingOfferArray(raw []interface{}) (FundingOffer, bool) {
+	offer, field := parseFundingOfferRow(raw)
+	return offer, field == ""
+}
+
+// parseFundingOfferList parses the rows of an offer list response, returning
+// the rows it had to skip
+func parseFundingOfferList(rows [][]interface{}) ([]FundingOffer, []ParseError) {
+	offers := make([]FundingOffer, 0, len(rows))
+	var skipped []ParseError
+	for i, raw := range rows {
+		offer, field := parseFundingOfferRow(raw)
+		if field != "" {
+			skipped = append(skipped, newParseError(i, field, raw))
+			continue
+		}
+		offers = append(offers, offer)
+	}
+	return offers, skipped
+}
+
+// parseFundingOfferRow is like parseFundingOfferArray but names the first
+// missing or invalid field instead of reporting a bool ("" on success)
+func parseFundingOfferRow(raw []interface{}) (FundingOffer, string) {
+	if len(raw) < 20 {
+		return FundingOffer{}, "length"
+	}
+
+	id, okID := util.FieldInt(raw, 0)
+	symbol, okSymbol := util.FieldString(raw, 1)
+	created, okCreated := parseMillisTimestamp(raw[2])
+	updated, okUpdated := parseMillisTimestamp(raw[3])
+	amount, okAmount := util.FieldFloat(raw, 4)
+	amountOrig, okAmountOrig := util.FieldFloat(raw, 5)
+	rate, okRate := util.FieldFloat(raw, 14)
+	period, okPeriod := util.FieldInt(raw, 15)
+
+	if field := firstInvalid(
+		fieldCheck{"ID", okID}, fieldCheck{"SYMBOL", okSymbol},
+		fieldCheck{"MTS_CREATED", okCreated}, fieldCheck{"MTS_UPDATED", okUpdated},
+		fieldCheck{"AMOUNT", okAmount}, fieldCheck{"AMOUNT_ORIG", okAmountOrig},
+		fieldCheck{"RATE", okRate}, fieldCheck{"PERIOD", okPeriod},
+	); field != "" {
+		return FundingOffer{}, field
+	}
+
+	offerType, _ := util.FieldString(raw, 6)
+	flags, _ := util.FieldInt(raw, 9)
+	status, _ := util.FieldString(raw, 10)
+	notify, _ := util.FieldInt(raw, 16) // Bitfinex sends 0 or 1
+	hidden, _ := util.FieldInt(raw, 17)
+	renew, _ := util.FieldInt(raw, 19)
+
+	return FundingOffer{
+		ID:             id,
+		Symbol:         symbol,
+		CreatedAt:      created,
+		UpdatedAt:      updated,
+		Amount:         amount,
+		AmountOriginal: amountOrig,
+		Type:           offerType,
+		Flags:          flags,
+		Status:         status,
+		Rate:           rate,
+		Period:         period,
+		Notify:         notify != 0,
+		Hidden:         hidden,
+		Renew:          renew != 0,
+	}, ""
+}
+
+// Notification represents the acknowledgement Bitfinex returns for write
+// requests
+// Bitfinex API returns format:
+// [MTS, TYPE, MESSAGE_ID, _, NOTIFY_INFO, CODE, STATUS, TEXT]
+type Notification struct {
+	Type   string // Request type (e.g. fon-req, foc-req)
+	Code   string // Optional error code
+	Status string // SUCCESS, ERROR or FAILURE
+	Text   string // Human-readable description
+}
+
+// parseNotification extracts the acknowledgement fields from a write response
+func parseNotification(response []interface{}) Notification {
+	var n Notification
+	n.Type, _ = util.FieldString(response, 1)
+	if len(response) > 5 && response[5] != nil {
+		n.Code = fmt.Sprint(response[5])
+	}
+	n.Status, _ = util.FieldString(response, 6)
+	n.Text, _ = util.FieldString(response, 7)
+	return n
+}
+
+// GetFundingCredits retrieves the funds currently lent out for a symbol
+func (c *Client) GetFundingCredits(symbol string) ([]FundingCredit, error) {
+	return c.GetFundingCreditsContext(context.Background(), symbol)
+}
+
+// GetFundingCreditsContext is like GetFundingCredits but honors ctx for cancellation
+func (c *Client) GetFundingCreditsContext(ctx context.Context, symbol string) ([]FundingCredit, error) {
+	path := fmt.Sprintf("v2/auth/r/funding/credits/%s", symbol)
+	respBody, err := c.SendRequestContext(ctx, "POST", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funding credits: %w", err)
+	}
+
+	var rawCredits [][]interface{}
+	if err := json.Unmarshal(respBody, &rawCredits); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	credits := make([]FundingCredit, 0, len(rawCredits))
+	for _, raw := range rawCredits {
+		credit, ok := parseFundingCreditArray(raw)
+		if !ok {
+			continue
+		}
+		credits = append(credits, credit)
+	}
+
+	return credits, nil
+}
+
+// parseFundingCreditArray converts a Bitfinex funding credit array into a FundingCredit
+// Bitfinex API returns format:
+// [ID, SYMBOL, SIDE, MTS_CREATE, MTS_UPDATE, AMOUNT, FLAGS, STATUS, RATE_TYPE, _, _, RATE, PERIOD, MTS_OPENING, ...]
+func parseFundingCreditArray(raw []interface{}) (FundingCredit, bool) {
+	if len(raw) < 14 {
+		return FundingCredit{}, false
+	}
+
+	id, okID := util.FieldInt64(raw, 0)
+	amount, okAmount := util.FieldFloat(raw, 5)
+	if !okID || !okAmount {
+		return FundingCredit{}, false
+	}
+
+	symbol, _ := util.FieldString(raw, 1)
+	status, _ := util.FieldString(raw, 7)
+	rate, _ := util.FieldFloat(raw, 11) // null for FRR loans
+	period, _ := util.FieldInt(raw, 12)
+	opened, _ := parseMillisTimestamp(raw[13])
+
+	return FundingCredit{
+		ID:       id,
+		Symbol:   symbol,
+		Status:   status,
+		Amount:   amount,
+		Rate:     rate,
+		Period:   period,
+		OpenedAt: opened,
+	}, true
+}
+
+// cancelCountPattern extracts the number of offers from a cancel-all
+// acknowledgement text
+var cancelCountPattern = regexp.MustCompile(`([0-9]+)`)
+
+// CancelAllFundingOffers cancels every active funding offer for a symbol
+// (fUSD or USD) in a single request. It returns the number of offers
+// cancelled when the exchange reports it, otherwise 0.
+func (c *Client) CancelAllFundingOffers(symbol string) (int, error) {
+	return c.CancelAllFundingOffersContext(context.Background(), symbol)
+}
+
+// CancelAllFundingOffersContext is like CancelAllFundingOffers but honors ctx for cancellation
+func (c *Client) CancelAllFundingOffersContext(ctx context.Context, symbol string) (int, error) {
+	currency := strings.TrimPrefix(symbol, "f")
+	if currency == "" {
+		return 0, fmt.Errorf("symbol cannot be empty")
+	}
+
+	payload := map[string]interface{}{
+		"currency": currency,
+	}
+
+	respBody, err := c.SendRequestContext(ctx, "POST", "v2/auth/w/funding/offer/cancel/all", payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel all funding offers: %w", err)
+	}
+
+	var response []interface{}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	ack := parseNotification(response)
+	if ack.Status != "SUCCESS" {
+		return 0, fmt.Errorf("failed to cancel all funding offers: %s", ack.Text)
+	}
+
+	count := 0
+	if m := cancelCountPattern.FindStringSubmatch(ack.Text); m != nil {
+		count, _ = strconv.Atoi(m[1])
+	}
+	return count, nil
+}
+
+// FundingInfo represents the account's funding yield and duration averages
+// for a symbol
+type FundingInfo struct {
+	Symbol       string  // Funding symbol (fUSD, ...)
+	YieldLoan    float64 // Average rate paid on taken loans
+	YieldLend    float64 // Average rate earned on lent funds
+	DurationLoan float64 // Average duration of taken loans (days)
+	DurationLend float64 // Average duration of lent funds (days)
+}
+
+// GetFundingInfo retrieves the account's funding info for a symbol
+func (c *Client) GetFundingInfo(symbol string) (*FundingInfo, error) {
+	return c.GetFundingInfoContext(context.Background(), symbol)
+}
+
+// GetFundingInfoContext is like GetFundingInfo but honors ctx for cancellation
+func (c *Client) GetFundingInfoContext(ctx context.Context, symbol string) (*FundingInfo, error) {
+	path := fmt.Sprintf("v2/auth/r/info/funding/%s", symbol)
+	respBody, err := c.SendRequestContext(ctx, "POST", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funding info: %w", err)
+	}
+
+	// Bitfinex API returns format:
+	// ["sym", SYMBOL, [YIELD_LOAN, YIELD_LEND, DURATION_LOAN, DURATION_LEND]]
+	var response []interface{}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(response) < 3 {
+		return nil, fmt.Errorf("invalid response format")
+	}
+
+	values, ok := response[2].([]interface{})
+	if !ok || len(values) < 4 {
+		return nil, fmt.Errorf("invalid funding info format")
+	}
+
+	info := &FundingInfo{}
+	info.Symbol, _ = util.FieldString(response, 1)
+	info.YieldLoan, _ = util.FieldFloat(values, 0)
+	info.YieldLend, _ = util.FieldFloat(values, 1)
+	info.DurationLoan, _ = util.FieldFloat(values, 2)
+	info.DurationLend, _ = util.FieldFloat(values, 3)
+
+	return info, nil
+}
+
+// FundingTicker is a snapshot of a funding symbol's FRR and best rates
+type FundingTicker struct {
+	FRR                float64 // Flash Return Rate (daily)
+	Bid                float64 // Best bid rate (daily)
+	BidPeriod          int     // Period of the best bid (days)
+	BidSize            float64 // Amount at the best bids
+	Ask                float64 // Best ask rate (daily)
+	AskPeriod          int     // Period of the best ask (days)
+	AskSize            float64 // Amount at the best asks
+	DailyChange        float64 // Rate change over the last 24 hours
+	DailyChangePerc    float64 // Relative rate change over the last 24 hours
+	LastPrice          float64 // Rate of the last trade
+	Volume             float64 // Amount traded over the last 24 hours
+	High               float64 // Highest rate over the last 24 hours
+	Low                float64 // Lowest rate over the last 24 hours
+	FRRAmountAvailable float64 // Amount offered at FRR
+}
+
+// GetFundingTicker retrieves the ticker of a funding symbol, a one-call
+// alternative to the funding stats and book for FRR and the best rates
+func (c *Client) GetFundingTicker(symbol string) (*FundingTicker, error) {
+	return c.GetFundingTickerContext(context.Background(), symbol)
+}
+
+// GetFundingTickerContext is like GetFundingTicker but honors ctx for cancellation
+func (c *Client) GetFundingTickerContext(ctx context.Context, symbol string) (*FundingTicker, error) {
+	path := fmt.Sprintf("v2/ticker/%s", symbol)
+	respBody, err := c.SendPublicRequestContext(ctx, "GET", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funding ticker: %w", err)
+	}
+
+	// Bitfinex API returns format:
+	// [FRR, BID, BID_PERIOD, BID_SIZE, ASK, ASK_PERIOD, ASK_SIZE, DAILY_CHANGE,
+	//  DAILY_CHANGE_PERC, LAST_PRICE, VOLUME, HIGH, LOW, _, _, FRR_AMOUNT_AVAILABLE]
+	var raw []interface{}
+	if err := json.Unmarshal(respBody, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	frr, okFRR := util.FieldFloat(raw, 0)
+	bid, okBid := util.FieldFloat(raw, 1)
+	ask, okAsk := util.FieldFloat(raw, 4)
+	if !okFRR || !okBid || !okAsk {
+		return nil, fmt.Errorf("invalid funding ticker format")
+	}
+
+	ticker := &FundingTicker{FRR: frr, Bid: bid, Ask: ask}
+	ticker.BidPeriod, _ = util.FieldInt(raw, 2)
+	ticker.BidSize, _ = util.FieldFloat(raw, 3)
+	ticker.AskPeriod, _ = util.FieldInt(raw, 5)
+	ticker.AskSize, _ = util.FieldFloat(raw, 6)
+	ticker.DailyChange, _ = util.FieldFloat(raw, 7)
+	ticker.DailyChangePerc, _ = util.FieldFloat(raw, 8)
+	ticker.LastPrice, _ = util.FieldFloat(raw, 9)
+	ticker.Volume, _ = util.FieldFloat(raw, 10)
+	ticker.High, _ = util.FieldFloat(raw, 11)
+	ticker.Low, _ = util.FieldFloat(raw, 12)
+	ticker.FRRAmountAvailable, _ = util.FieldFloat(raw, 15)
+
+	c.recordRate(symbol, RatePoint{Time: time.Now().UTC(), FRR: frr, Bid: bid, Ask: ask})
+	return ticker, nil
+}
+
+// FundingTrade represents an executed funding trade
+type FundingTrade struct {
+	ID        int64     // Trade ID
+	Symbol    string    // Funding symbol (fUSD, ...)
+	CreatedAt time.Time // Execution time
+	OfferID   int64     // ID of the offer that was filled
+	Amount    float64   // Amount (positive when lending)
+	Rate      float64   // Daily rate
+	Period    int       // Period in days
+}
+
+// GetFundingTrades retrieves executed funding trades for a symbol. start and
+// end are millisecond timestamps and limit caps the number of results; zero
+// values leave the exchange defaults. Page backwards by passing the oldest
+// returned trade time as the next end.
+func (c *Client) GetFundingTrades(symbol string, start, end int64, limit int) ([]FundingTrade, error) {
+	return c.GetFundingTradesContext(context.Background(), symbol, start, end, limit)
+}
+
+// GetFundingTradesContext is like GetFundingTrades but honors ctx for cancellation
+func (c *Client) GetFundingTradesContext(ctx context.Context, symbol string, start, end int64, limit int) ([]FundingTrade, error) {
+	path := fmt.Sprintf("v2/auth/r/funding/trades/%s/hist", symbol)
+
+	respBody, err := c.SendRequestContext(ctx, "POST", path, historyParams(start, end, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funding trades: %w", err)
+	}
+
+	// Bitfinex API returns format:
+	// [[ID, SYMBOL, MTS_CREATE, OFFER_ID, AMOUNT, RATE, PERIOD, ...], ...]
+	var rawTrades [][]interface{}
+	if err := json.Unmarshal(respBody, &rawTrades); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	trades := make([]FundingTrade, 0, len(rawTrades))
+	for _, raw := range rawTrades {
+		id, okID := util.FieldInt64(raw, 0)
+		created, okCreated := util.FieldInt64(raw, 2)
+		amount, okAmount := util.FieldFloat(raw, 4)
+		rate, okRate := util.FieldFloat(raw, 5)
+		if !okID || !okCreated || !okAmount || !okRate {
+			continue
+		}
+
+		tradeSymbol, _ := util.FieldString(raw, 1)
+		offerID, _ := util.FieldInt64(raw, 3)
+		period, _ := util.FieldInt(raw, 6)
+
+		trades = append(trades, FundingTrade{
+			ID:        id,
+			Symbol:    tradeSymbol,
+			CreatedAt: time.UnixMilli(created).UTC(),
+			OfferID:   offerID,
+			Amount:    amount,
+			Rate:      rate,
+			Period:    period,
+		})
+	}
+
+	return trades, nil
+}
+
+// Candle is an OHLC candle of funding rates
+type Candle struct {
+	Time   time.Time // Candle open time
+	Open   float64   // First rate
+	Close  float64   // Last rate
+	High   float64   // Highest rate
+	Low    float64   // Lowest rate
+	Volume float64   // Amount traded
+}
+
+// candleTimeframes are the timeframes the candles endpoint accepts
+var candleTimeframes = map[string]bool{
+	"1m": true, "5m": true, "15m": true, "30m": true,
+	"1h": true, "3h": true, "6h": true, "12h": true,
+	"1D": true, "1W": true, "14D": true, "1M": true,
+}
+
+// GetFundingCandles retrieves funding rate candles for symbol and a funding
+// period in days. timeframe is one of 1m, 5m, 15m, 30m, 1h, 3h, 6h, 12h, 1D,
+// 1W, 14D or 1M; start, end and limit behave as in GetFundingTrades.
+func (c *Client) GetFundingCandles(symbol string, timeframe string, period int, start, end int64, limit int) ([]Candle, error) {
+	return c.GetFundingCandlesContext(context.Background(), symbol, timeframe, period, start, end, limit)
+}
+
+// GetFundingCandlesContext is like GetFundingCandles but honors ctx for cancellation
+func (c *Client) GetFundingCandlesContext(ctx context.Context, symbol string, timeframe string, period int, start, end int64, limit int) ([]Candle, error) {
+	if !candleTimeframes[timeframe] {
+		return nil, fmt.Errorf("invalid candle timeframe %q", timeframe)
+	}
+	if period < MinFundingPeriod || period > MaxFundingPeriod {
+		return nil, fmt.Errorf("period must be between %d and %d days", MinFundingPeriod, MaxFundingPeriod)
+	}
+
+	path := withQuery(fmt.Sprintf("v2/candles/trade:%s:%s:p%d/hist", timeframe, symbol, period),
+		historyQuery(start, end, limit))
+	respBody, err := c.SendPublicRequestContext(ctx, "GET", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funding candles: %w", err)
+	}
+
+	// Bitfinex API returns format:
+	// [[MTS, OPEN, CLOSE, HIGH, LOW, VOLUME], ...]
+	var rawCandles [][]interface{}
+	if err := json.Unmarshal(respBody, &rawCandles); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	candles := make([]Candle, 0, len(rawCandles))
+	for _, raw := range rawCandles {
+		mts, okTime := util.FieldInt64(raw, 0)
+		open, okOpen := util.FieldFloat(raw, 1)
+		closeRate, okClose := util.FieldFloat(raw, 2)
+		high, okHigh := util.FieldFloat(raw, 3)
+		low, okLow := util.FieldFloat(raw, 4)
+		if !okTime || !okOpen || !okClose || !okHigh || !okLow {
+			continue
+		}
+		volume, _ := util.FieldFloat(raw, 5)
+
+		candles = append(candles, Candle{
+			Time:   time.UnixMilli(mts).UTC(),
+			Open:   open,
+			Close:  closeRate,
+			High:   high,
+			Low:    low,
+			Volume: volume,
+		})
+	}
+
+	return candles, nil
+}
+
+// historyParams builds the signed body of a history endpoint. Authenticated
+// endpoints take their parameters in the body; zero values are omitted.
+func historyParams(start, end int64, limit int) map[string]interface{} {
+	params := map[string]interface{}{}
+	if start > 0 {
+		params["start"] = start
+	}
+	if end > 0 {
+		params["end"] = end
+	}
+	if limit > 0 {
+		params["limit"] = limit
+	}
+	return params
+}
+
+// LedgerCategoryInterestPayment is the ledger category of funding interest
+// payments, for use with GetLedgers
+const LedgerCategoryInterestPayment = 28
+
+// LedgerEntry represents a balance change in a wallet
+type LedgerEntry struct {
+	ID          int64     // Ledger entry ID
+	Currency    string    // Currency code (USD, UST, ...)
+	CreatedAt   time.Time // Time of the balance change
+	Amount      float64   // Amount changed (negative for debits)
+	Balance     float64   // Wallet balance after the change
+	Description string    // Description, e.g. "Margin Funding Payment on wallet funding"
+}
+
+// GetLedgers retrieves ledger entries for a currency. category filters by
+// entry type (0 for all, e.g. LedgerCategoryInterestPayment); start, end and
+// limit behave as in GetFundingTrades.
+func (c *Client) GetLedgers(currency string, category int, start, end int64, limit int) ([]LedgerEntry, error) {
+	return c.GetLedgersContext(context.Background(), currency, category, start, end, limit)
+}
+
+// GetLedgersContext is like GetLedgers but honors ctx for cancellation
+func (c *Client) GetLedgersContext(ctx context.Context, currency string, category int, start, end int64, limit int) ([]LedgerEntry, error) {
+	path := fmt.Sprintf("v2/auth/r/ledgers/%s/hist", currency)
+
+	params := historyParams(start, end, limit)
+	if category > 0 {
+		params["category"] = category
+	}
+
+	respBody, err := c.SendRequestContext(ctx, "POST", path, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledgers: %w", err)
+	}
+
+	// Bitfinex API returns format:
+	// [[ID, CURRENCY, _, MTS, _, AMOUNT, BALANCE, _, DESCRIPTION], ...]
+	var rawEntries [][]interface{}
+	if err := json.Unmarshal(respBody, &rawEntries); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	entries := make([]LedgerEntry, 0, len(rawEntries))
+	for _, raw := range rawEntries {
+		id, okID := util.FieldInt64(raw, 0)
+		created, okCreated := util.FieldInt64(raw, 3)
+		amount, okAmount := util.FieldFloat(raw, 5)
+		if !okID || !okCreated || !okAmount {
+			continue
+		}
+
+		entryCurrency, _ := util.FieldString(raw, 1)
+		balance, _ := util.FieldFloat(raw, 6)
+		description, _ := util.FieldString(raw, 8)
+
+		entries = append(entries, LedgerEntry{
+			ID:          id,
+			Currency:    entryCurrency,
+			CreatedAt:   time.UnixMilli(created).UTC(),
+			Amount:      amount,
+			Balance:     balance,
+			Description: description,
+		})
+	}
+
+	return entries, nil
+}
+
+// Movement represents a deposit or withdrawal
+type Movement struct {
+	ID        int64     // Movement ID
+	Currency  string    // Currency code (USD, UST, ...)
+	StartedAt time.Time // Time the movement was requested
+	UpdatedAt time.Time // Time of the last status change
+	Status    string    // Status (COMPLETED, PENDING, CANCELED, ...)
+	Amount    float64   // Amount moved (positive for deposits, negative for withdrawals)
+	Fees      float64   // Fees charged (negative)
+}
+
+// GetMovements retrieves deposits and withdrawals for a currency, or for
+// every currency when currency is empty. start, end and limit behave as in
+// GetFundingTrades.
+func (c *Client) GetMovements(currency string, start, end int64, limit int) ([]Movement, error) {
+	return c.GetMovementsContext(context.Background(), currency, start, end, limit)
+}
+
+// GetMovementsContext is like GetMovements but honors ctx for cancellation
+func (c *Client) GetMovementsContext(ctx context.Context, currency string, start, end int64, limit int) ([]Movement, error) {
+	path := "v2/auth/r/movements/hist"
+	if currency != "" {
+		path = fmt.Sprintf("v2/auth/r/movements/%s/hist", currency)
+	}
+
+	respBody, err := c.SendRequestContext(ctx, "POST", path, historyParams(start, end, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get movements: %w", err)
+	}
+
+	// Bitfinex API returns format:
+	// [[ID, CURRENCY, CURRENCY_NAME, _, _, MTS_STARTED, MTS_UPDATED, _, _, STATUS, _, _, AMOUNT, FEES, ...], ...]
+	var rawMovements [][]interface{}
+	if err := json.Unmarshal(respBody, &rawMovements); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	movements := make([]Movement, 0, len(rawMovements))
+	for _, raw := range rawMovements {
+		id, okID := util.FieldInt64(raw, 0)
+		started, okStarted := util.FieldInt64(raw, 5)
+		amount, okAmount := util.FieldFloat(raw, 12)
+		if !okID || !okStarted || !okAmount {
+			continue
+		}
+
+		movementCurrency, _ := util.FieldString(raw, 1)
+		updated, _ := util.FieldInt64(raw, 6)
+		status, _ := util.FieldString(raw, 9)
+		fees, _ := util.FieldFloat(raw, 13)
+
+		movements = append(movements, Movement{
+			ID:        id,
+			Currency:  movementCurrency,
+			StartedAt: time.UnixMilli(started).UTC(),
+			UpdatedAt: time.UnixMilli(updated).UTC(),
+			Status:    status,
+			Amount:    amount,
+			Fees:      fees,
+		})
+	}
+
+	return movements, nil
 }
 
-// CancelFundingOffer cancels an existing funding offer
-func (c *Client) CancelFundingOffer(offerID int) error {
+// AutoRenewRequest configures Bitfinex auto-renew for a funding currency.
+// When enabled, returned loans are re-offered automatically at expiry.
+type AutoRenewRequest struct {
+	Currency string  // Currency code (USD, UST, ...)
+	Enabled  bool    // Turn auto-renew on or off
+	Amount   float64 // Amount to keep lent (0 means the whole balance)
+	Rate     float64 // Daily rate (0 means FRR)
+	Period   int     // Period in days
+}
+
+// SetFundingAutoRenew enables or disables auto-renew for a currency
+func (c *Client) SetFundingAutoRenew(req AutoRenewRequest) error {
+	return c.SetFundingAutoRenewContext(context.Background(), req)
+}
+
+// SetFundingAutoRenewContext is like SetFundingAutoRenew but honors ctx for cancellation
+func (c *Client) SetFundingAutoRenewContext(ctx context.Context, req AutoRenewRequest) error {
+	if req.Currency == "" {
+		return fmt.Errorf("currency cannot be empty")
+	}
+
+	payload := map[string]interface{}{
+		"currency": req.Currency,
+		"status":   0,
+	}
+	if req.Enabled {
+		if req.Period < MinFundingPeriod || req.Period > MaxFundingPeriod {
+			return fmt.Errorf("period must be between %d and %d days", MinFundingPeriod, MaxFundingPeriod)
+		}
+		payload["status"] = 1
+		payload["period"] = req.Period
+		if req.Amount > 0 {
+			payload["amount"] = strconv.FormatFloat(req.Amount, 'f', -1, 64)
+		}
+		if req.Rate > 0 {
+			// The endpoint expects the rate as a percentage
+			payload["rate"] = strconv.FormatFloat(req.Rate*100, 'f', -1, 64)
+		}
+	}
+
+	respBody, err := c.SendRequestContext(ctx, "POST", "v2/auth/w/funding/auto", payload)
+	if err != nil {
+		return fmt.Errorf("failed to set funding auto-renew: %w", err)
+	}
+
+	var response []interface{}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if ack := parseNotification(response); ack.Status != "SUCCESS" {
+		return fmt.Errorf("failed to set funding auto-renew: %s", ack.Text)
+	}
+
+	return nil
+}
+
+// CancelFundingOffer cancels an existing funding offer and returns the
+// offer as it was when cancelled. When Bitfinex acknowledges the
+// cancellation without readable offer details only ID is set.
+func (c *Client) CancelFundingOffer(offerID int) (*FundingOffer, error) {
+	return c.CancelFundingOfferContext(context.Background(), offerID)
+}
+
+// CancelFundingOfferContext is like CancelFundingOffer but honors ctx for cancellation
+func (c *Client) CancelFundingOfferContext(ctx context.Context, offerID int) (*FundingOffer, error) {
 	payload := map[string]interface{}{
 		"id": offerID,
 	}
 
 	// Send the request to cancel the funding offer
-	respBody, err := c.SendRequest("POST", "v2/auth/w/funding/offer/cancel", payload)
+	respBody, err := c.SendRequestContext(ctx, "POST", "v2/auth/w/funding/offer/cancel", payload)
 	if err != nil {
-		return fmt.Errorf("failed to cancel funding offer: %v", err)
+		return nil, fmt.Errorf("failed to cancel funding offer: %w", err)
 	}
 
 	// Parse the response
 	var response []interface{}
 	if err := json.Unmarshal(respBody, &response); err != nil {
-		return fmt.Errorf("failed to parse response: %v", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// The status is at index 6; the text after it may be missing
+	if len(response) < 7 {
+		return nil, fmt.Errorf("invalid response format: %s", string(respBody))
 	}
 
 	// Check if the response indicates success
-	if len(response) >= 7 && response[6].(string) != "SUCCESS" {
-		return fmt.Errorf("failed to cancel funding offer: %s", response[7].(string))
+	if ack := parseNotification(response); ack.Status != "SUCCESS" {
+		return nil, fmt.Errorf("failed to cancel funding offer: %w", BitfinexError{
+			StatusCode: http.StatusOK,
+			ErrorCode:  ack.Code,
+			Message:    ack.Text,
+			RawBody:    string(respBody),
+		})
 	}
 
-	return nil
+	// Extract the cancelled offer, which has the same layout as on submit.
+	// The cancellation succeeded either way, so an unreadable offer only
+	// leaves its details out.
+	offerData, _ := response[4].([]interface{})
+	offer, ok := parseFundingOfferArray(offerData)
+	if !ok {
+		return &FundingOffer{ID: offerID}, nil
+	}
+
+	return &offer, nil
 }
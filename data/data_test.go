@@ -0,0 +1,474 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// offerRow returns a valid funding offer row with notify and renew set
+func offerRow(notify, renew interface{}) []interface{} {
+	return []interface{}{float64(41), "fUSD", float64(1700000000000), float64(1700000000000),
+		float64(150), float64(150), "LIMIT", nil, nil, float64(0), "ACTIVE", nil, nil, nil,
+		0.0002, float64(2), notify, float64(0), nil, renew}
+}
+
+func TestParseFundingOfferRowFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		notify     interface{}
+		renew      interface{}
+		wantNotify bool
+		wantRenew  bool
+	}{
+		{"both off", float64(0), float64(0), false, false},
+		{"notify on", float64(1), float64(0), true, false},
+		{"renew on", float64(0), float64(1), false, true},
+		{"null fields", nil, nil, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offer, field := parseFundingOfferRow(offerRow(tt.notify, tt.renew))
+			if field != "" {
+				t.Fatalf("row rejected on %s", field)
+			}
+			if offer.Notify != tt.wantNotify || offer.Renew != tt.wantRenew {
+				t.Errorf("Notify, Renew = %v, %v; want %v, %v", offer.Notify, offer.Renew, tt.wantNotify, tt.wantRenew)
+			}
+		})
+	}
+}
+
+func TestCancelFundingOffer(t *testing.T) {
+	offer := `[41,"fUSD",1700000000000,1700000000000,150,150,"LIMIT",null,null,0,"CANCELED",null,null,null,0.0002,2,0,0,null,0]`
+
+	tests := []struct {
+		name       string
+		body       string
+		wantErr    bool
+		wantAmount float64
+	}{
+		{"cancelled offer", `[1,"foc-req",null,null,` + offer + `,null,"SUCCESS","Cancelled"]`, false, 150},
+		{"success without offer", `[1,"foc-req",null,null,null,null,"SUCCESS","Cancelled"]`, false, 0},
+		{"success with short offer", `[1,"foc-req",null,null,[41,"fUSD"],null,"SUCCESS","Cancelled"]`, false, 0},
+		{"error ack", `[1,"foc-req",null,null,null,null,"ERROR","offer not found"]`, true, 0},
+		{"error ack without text", `[1,"foc-req",null,null,null,null,"ERROR"]`, true, 0},
+		{"success ack without text", `[1,"foc-req",null,null,null,null,"SUCCESS"]`, false, 0},
+		{"truncated ack", `[1,"foc-req",null,null,null,null]`, true, 0},
+		{"empty array", `[]`, true, 0},
+		{"object", `{"error":"ERR_INVALID"}`, true, 0},
+		{"not json", `<html>Bad Gateway</html>`, true, 0},
+		{"null", `null`, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, cannedDoer(http.StatusOK, tt.body))
+			got, err := c.CancelFundingOffer(41)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got == nil || got.ID != 41 || got.Amount != tt.wantAmount {
+				t.Errorf("offer = %+v, want ID 41 with amount %v", got, tt.wantAmount)
+			}
+		})
+	}
+}
+
+func TestUpdateFundingOfferRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		offerType string
+		newRate   float64
+		submitErr bool
+		wantCalls []string
+		wantErr   bool
+		wantID    int
+	}{
+		{"limit", OfferTypeLimit, 0.0003, false, []string{"offers", "cancel", "submit"}, false, 42},
+		{"zero limit rate", OfferTypeLimit, 0, false, []string{"offers"}, true, 0},
+		{"zero frr delta", OfferTypeFRRDeltaVar, 0, false, []string{"offers", "cancel", "submit"}, false, 42},
+		{"resubmit fails", OfferTypeLimit, 0.0003, true, []string{"offers", "cancel", "submit"}, true, 41},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := func(id int, status string, rate float64) string {
+				return fmt.Sprintf(`[%d,"fUSD",1700000000000,1700000000000,150,150,%q,null,null,0,%q,null,null,null,%v,2,0,0,null,0]`,
+					id, tt.offerType, status, rate)
+			}
+
+			var calls []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v2/auth/r/funding/offers":
+					calls = append(calls, "offers")
+					fmt.Fprintf(w, "[%s]", row(41, "ACTIVE", 0.0002))
+				case "/v2/auth/w/funding/offer/cancel":
+					calls = append(calls, "cancel")
+					fmt.Fprintf(w, `[1,"foc-req",null,null,%s,null,"SUCCESS","Cancelled"]`, row(41, "CANCELED", 0.0002))
+				case "/v2/auth/w/funding/offer/submit":
+					calls = append(calls, "submit")
+					if tt.submitErr {
+						fmt.Fprint(w, `[1,"fon-req",null,null,null,null,"ERROR","Invalid offer"]`)
+						return
+					}
+					fmt.Fprintf(w, `[1,"fon-req",null,null,%s,null,"SUCCESS","Submitting"]`, row(42, "ACTIVE", tt.newRate))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			c := newTestClient(t, srv.Client(), WithBaseURL(srv.URL))
+			got, err := c.UpdateFundingOfferRate(41, tt.newRate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			gotID := 0
+			if got != nil {
+				gotID = got.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("returned offer ID = %d, want %d", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestNewClientBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{"trailing slash", "https://api.bitfinex.com/", "https://api.bitfinex.com", false},
+		{"several trailing slashes", "https://api.bitfinex.com//", "https://api.bitfinex.com", false},
+		{"unchanged", "http://127.0.0.1:8080", "http://127.0.0.1:8080", false},
+		{"no scheme", "api.bitfinex.com", "", true},
+		{"empty", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClientE("key", "secret", WithBaseURL(tt.baseURL))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && c.BaseURL != tt.want {
+				t.Errorf("BaseURL = %q, want %q", c.BaseURL, tt.want)
+			}
+
+			defer func() {
+				if panicked := recover() != nil; panicked != tt.wantErr {
+					t.Errorf("NewClient panicked = %v, want %v", panicked, tt.wantErr)
+				}
+			}()
+			NewClient("key", "secret", WithBaseURL(tt.baseURL))
+		})
+	}
+}
+
+func TestNearestValidPeriod(t *testing.T) {
+	tests := []struct {
+		in, want int
+	}{
+		{-5, 2},
+		{0, 2},
+		{1, 2},
+		{2, 2},
+		{30, 30},
+		{120, 120},
+		{121, 120},
+		{365, 120},
+	}
+
+	for _, tt := range tests {
+		if got := NearestValidPeriod(tt.in); got != tt.want {
+			t.Errorf("NearestValidPeriod(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSubmitFundingOfferRejectedAck(t *testing.T) {
+	offer := `[41,"fUSD",1700000000000,1700000000000,150,150,"LIMIT",null,null,0,"ACTIVE",null,null,null,0.0002,2,0,0,null,0]`
+
+	tests := []struct {
+		name        string
+		body        string
+		wantErr     bool
+		wantMinimum bool
+		wantIs      error
+	}{
+		{"success", `[1,"fon-req",null,null,` + offer + `,null,"SUCCESS","Submitting"]`, false, false, nil},
+		{"error ack", `[1,"fon-req",null,null,null,null,"ERROR","Invalid offer"]`, true, false, nil},
+		{"failure ack", `[1,"fon-req",null,null,null,null,"FAILURE","Invalid offer"]`, true, false, nil},
+		{"invalid amount", `[1,"fon-req",null,null,null,null,"ERROR","Invalid offer: amount must be at least 150"]`, true, false, nil},
+		{"minimum not met", `[1,"fon-req",null,null,null,null,"ERROR","Offer amount below minimum of 150"]`, true, true, ErrOfferMinimumNotMet},
+		{"insufficient balance", `[1,"fon-req",null,null,null,null,"ERROR","Invalid offer: not enough USD balance"]`, true, false, ErrInsufficientBalance},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, cannedDoer(http.StatusOK, tt.body))
+			got, err := c.SubmitFundingOffer(FundingOfferRequest{Type: OfferTypeLimit, Symbol: "fUSD", Amount: "150", Rate: "0.0002", Period: 2})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				if got == nil || got.ID != 41 {
+					t.Errorf("offer = %+v, want ID 41", got)
+				}
+				return
+			}
+			if got != nil {
+				t.Errorf("offer = %+v, want nil on a rejected ack", got)
+			}
+			var minErr *OfferMinimumError
+			if errors.As(err, &minErr) != tt.wantMinimum {
+				t.Errorf("err = %v, want OfferMinimumError %v", err, tt.wantMinimum)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("err = %v, want errors.Is %v", err, tt.wantIs)
+			}
+		})
+	}
+}
+
+func TestFundingFlags(t *testing.T) {
+	// Values from https://docs.bitfinex.com/docs/flag-values
+	values := []struct {
+		flag FundingFlags
+		want int
+	}{
+		{FundingFlagHidden, 64},
+		{FundingFlagClose, 512},
+		{FundingFlagNoVarRates, 524288},
+	}
+	for _, v := range values {
+		if int(v.flag) != v.want {
+			t.Errorf("flag = %d, want %d", v.flag, v.want)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		flags   FundingFlags
+		want    int
+		has     FundingFlags
+		wantHas bool
+	}{
+		{"with hidden", FundingFlags(0).With(FundingFlagHidden), 64, FundingFlagHidden, true},
+		{"with twice", FundingFlagHidden.With(FundingFlagHidden), 64, FundingFlagHidden, true},
+		{"combined", FundingFlagHidden.With(FundingFlagNoVarRates), 524352, FundingFlagNoVarRates, true},
+		{"without", FundingFlagHidden.With(FundingFlagClose).Without(FundingFlagHidden), 512, FundingFlagHidden, false},
+		{"without unset", FundingFlagClose.Without(FundingFlagHidden), 512, FundingFlagClose, true},
+		{"has needs every bit", FundingFlagHidden, 64, FundingFlagHidden.With(FundingFlagClose), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if int(tt.flags) != tt.want {
+				t.Errorf("flags = %d, want %d", tt.flags, tt.want)
+			}
+			if got := tt.flags.Has(tt.has); got != tt.wantHas {
+				t.Errorf("Has(%d) = %v, want %v", tt.has, got, tt.wantHas)
+			}
+		})
+	}
+}
+
+func TestOfferBuilderHidden(t *testing.T) {
+	tests := []struct {
+		name   string
+		flags  FundingFlags
+		hidden bool
+		want   int
+	}{
+		{"hidden", 0, true, 64},
+		{"keeps other flags", FundingFlagNoVarRates, true, 524352},
+		{"clears hidden", FundingFlagHidden.With(FundingFlagClose), false, 512},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewOfferBuilder("fUSD").Amount(150).Rate(0.0002).Period(2).Flags(tt.flags).Hidden(tt.hidden).Build()
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			if req.Flags != tt.want {
+				t.Errorf("Flags = %d, want %d", req.Flags, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetFundingAutoRenewPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		req  AutoRenewRequest
+		want string
+	}{
+		{"disable", AutoRenewRequest{Currency: "USD"}, `{"currency":"USD","status":0}`},
+		{"enable", AutoRenewRequest{Currency: "USD", Enabled: true, Period: 30}, `{"currency":"USD","period":30,"status":1}`},
+		{"enable with rate", AutoRenewRequest{Currency: "USD", Enabled: true, Period: 2, Amount: 500, Rate: 0.0002},
+			`{"amount":"500","currency":"USD","period":2,"rate":"0.02","status":1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body string
+			d := doerFunc(func(req *http.Request) (*http.Response, error) {
+				b, err := io.ReadAll(req.Body)
+				if err != nil {
+					return nil, err
+				}
+				body = string(b)
+				return cannedDoer(http.StatusOK, `[1,"fa-req",null,null,null,null,"SUCCESS","Updated"]`).Do(req)
+			})
+
+			c := newTestClient(t, d)
+			if err := c.SetFundingAutoRenew(tt.req); err != nil {
+				t.Fatalf("SetFundingAutoRenew: %v", err)
+			}
+			if body != tt.want {
+				t.Errorf("body = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendRequestNormalizesPath(t *testing.T) {
+	// Signature computed independently over the documented payload
+	mac := hmac.New(sha512.New384, []byte("secret"))
+	mac.Write([]byte("/api/v2/auth/r/wallets1700000000000"))
+	wantSignature := hex.EncodeToString(mac.Sum(nil))
+	const wantURL = "https://api.bitfinex.com/v2/auth/r/wallets"
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"no leading slash", "v2/auth/r/wallets"},
+		{"leading slash", "/v2/auth/r/wallets"},
+		{"several leading slashes", "//v2/auth/r/wallets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			d := doerFunc(func(req *http.Request) (*http.Response, error) {
+				got = req
+				return cannedDoer(http.StatusOK, `[]`).Do(req)
+			})
+
+			c := newTestClient(t, d, WithNonceSource(func() int64 { return 1700000000000 }))
+			if _, err := c.SendRequest("POST", tt.path, nil); err != nil {
+				t.Fatalf("SendRequest: %v", err)
+			}
+			if got.URL.String() != wantURL {
+				t.Errorf("URL = %s, want %s", got.URL, wantURL)
+			}
+			if nonce := got.Header.Get("bfx-nonce"); nonce != "1700000000000" {
+				t.Errorf("bfx-nonce = %s, want 1700000000000", nonce)
+			}
+			if signature := got.Header.Get("bfx-signature"); signature != wantSignature {
+				t.Errorf("bfx-signature = %s, want %s", signature, wantSignature)
+			}
+		})
+	}
+}
+
+// statRow is a funding stats row: MTS, FRR, AVG_PERIOD, FUNDING_AMOUNT,
+// FUNDING_AMOUNT_USED and FUNDING_BELOW_THRESHOLD at indexes 0, 3, 4, 7, 8
+// and 11
+const statRow = `[1700000000000,null,null,0.0002,2.5,null,null,1000000,500000,null,null,1000]`
+
+func TestParseFundingStats(t *testing.T) {
+	want := FundingStat{Timestamp: 1700000000000, FRR: 0.0002, AveragePeriod: 2.5,
+		FundingAmount: 1000000, FundingAmountUsed: 500000, FundingBelowThreshold: 1000}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantErr     bool
+		wantStats   int
+		wantSkipped []string // Field of each skipped row
+	}{
+		{"valid row", `[` + statRow + `]`, false, 1, nil},
+		{"valid and malformed rows", `[` + statRow + `,[1700000000000,null,null,"x",2.5,null,null,1,1,null,null,0]]`, false, 1, []string{"FRR"}},
+		{"short row", `[` + statRow + `,[1700000000000,null,null,0.0002]]`, false, 1, []string{"length"}},
+		{"empty", `[]`, false, 0, nil},
+		{"not json", `{"error":"ERR"}`, true, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFundingStats([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(result.Stats) != tt.wantStats {
+				t.Fatalf("parsed %d stats, want %d", len(result.Stats), tt.wantStats)
+			}
+			if tt.wantStats > 0 && result.Stats[0] != want {
+				t.Errorf("stat = %+v, want %+v", result.Stats[0], want)
+			}
+			if len(result.SkippedRows) != len(tt.wantSkipped) {
+				t.Fatalf("skipped %v, want fields %v", result.SkippedRows, tt.wantSkipped)
+			}
+			for i, field := range tt.wantSkipped {
+				if result.SkippedRows[i].Index != i+1 || result.SkippedRows[i].Field != field {
+					t.Errorf("skipped row %+v, want row %d on %s", result.SkippedRows[i], i+1, field)
+				}
+			}
+		})
+	}
+}
+
+func TestGetFundingStatForPeriod(t *testing.T) {
+	tests := []struct {
+		name    string
+		period  int
+		body    string
+		wantErr bool
+		wantLen int
+	}{
+		{"tags the period", 30, `[` + statRow + `,` + statRow + `]`, false, 2},
+		{"period too short", 1, `[` + statRow + `]`, true, 0},
+		{"period too long", 121, `[` + statRow + `]`, true, 0},
+		{"every row malformed", 2, `[[1700000000000]]`, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, cannedDoer(http.StatusOK, tt.body))
+			stats, err := c.GetFundingStatForPeriod("fUSD", tt.period, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(stats) != tt.wantLen {
+				t.Fatalf("got %d stats, want %d", len(stats), tt.wantLen)
+			}
+			for _, stat := range stats {
+				if stat.Period != tt.period {
+					t.Errorf("Period = %d, want %d", stat.Period, tt.period)
+				}
+			}
+		})
+	}
+}
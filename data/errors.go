@@ -0,0 +1,151 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrOfferMinimumNotMet is returned when the exchange rejects an offer whose
+// amount is below its current minimum
+var ErrOfferMinimumNotMet = errors.New("funding offer minimum not met")
+
+// ErrUnreachable is returned by Ping when the API cannot be reached
+var ErrUnreachable = errors.New("bitfinex API unreachable")
+
+// Sentinel errors matched by BitfinexError with errors.Is:
+//
+//	ErrUnauthorized        codes 10100-10113 (auth failed, bad payload,
+//	                       signature or HMAC), HTTP 401/403, or "apikey"
+//	                       in the message
+//	ErrNonceTooSmall       code 10114, or "nonce" in the message
+//	ErrRateLimited         code 11010, or HTTP 429
+//	ErrInsufficientBalance "not enough" or "insufficient" balance messages;
+//	                       Bitfinex reports these with the generic code 10001
+var (
+	ErrUnauthorized        = errors.New("bitfinex API credentials rejected")
+	ErrNonceTooSmall       = errors.New("bitfinex nonce too small")
+	ErrRateLimited         = errors.New("bitfinex rate limit exceeded")
+	ErrInsufficientBalance = errors.New("bitfinex insufficient balance")
+)
+
+// Is reports whether the error corresponds to one of the sentinel errors
+func (e BitfinexError) Is(target error) bool {
+	msg := strings.ToLower(e.Message)
+
+	switch target {
+	case ErrUnauthorized:
+		switch e.ErrorCode {
+		case "10100", "10111", "10112", "10113":
+			return true
+		}
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+			strings.Contains(msg, "apikey")
+	case ErrNonceTooSmall:
+		return e.ErrorCode == "10114" || strings.Contains(msg, "nonce")
+	case ErrRateLimited:
+		return e.ErrorCode == "11010" || e.StatusCode == http.StatusTooManyRequests
+	case ErrInsufficientBalance:
+		return strings.Contains(msg, "balance") &&
+			(strings.Contains(msg, "not enough") || strings.Contains(msg, "insufficient"))
+	}
+	return false
+}
+
+// ParseError describes a response row that did not match the expected
+// array layout and was skipped
+type ParseError struct {
+	Index int    // Position of the row in the response
+	Field string // First missing or invalid field, e.g. "RATE", or "length"
+	Raw   string // The row as JSON
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("row %d: invalid %s: %s", e.Index, e.Field, e.Raw)
+}
+
+// newParseError records row index of a response as skipped because of field
+func newParseError(index int, field string, row interface{}) ParseError {
+	raw, err := json.Marshal(row)
+	if err != nil {
+		raw = []byte(fmt.Sprint(row))
+	}
+	return ParseError{Index: index, Field: field, Raw: string(raw)}
+}
+
+// fieldCheck is the outcome of parsing one named field of a row
+type fieldCheck struct {
+	name string
+	ok   bool
+}
+
+// firstInvalid returns the name of the first check that failed, or "" when
+// all passed
+func firstInvalid(checks ...fieldCheck) string {
+	for _, check := range checks {
+		if !check.ok {
+			return check.name
+		}
+	}
+	return ""
+}
+
+// checkSkippedRows logs the rows skipped while parsing a list of what at
+// debug level. It fails when rows were returned but none could be parsed,
+// which usually means the response layout changed.
+func (c *Client) checkSkippedRows(what string, parsed int, skipped []ParseError) error {
+	for _, row := range skipped {
+		c.log().Debugf("Skipped unparsable %s %v", what, row)
+	}
+	if parsed == 0 && len(skipped) > 0 {
+		return fmt.Errorf("none of %d %s rows could be parsed, the response layout may have changed: %w",
+			len(skipped), what, skipped[0])
+	}
+	return nil
+}
+
+// minimumAmountPattern extracts the minimum from messages such as
+// "Invalid offer: incorrect amount, minimum is 150 dollar or equivalent in USD"
+var minimumAmountPattern = regexp.MustCompile(`minimum is ([0-9]+(?:\.[0-9]+)?)`)
+
+// OfferMinimumError reports an offer rejected for being below the exchange
+// minimum. It matches ErrOfferMinimumNotMet with errors.Is.
+type OfferMinimumError struct {
+	Minimum float64 // Minimum reported by the exchange or MinFundingAmount (0 if not reported)
+	Err     error   // Underlying API error
+}
+
+func (e *OfferMinimumError) Error() string {
+	if e.Minimum > 0 {
+		return fmt.Sprintf("%v (exchange minimum: %.2f): %v", ErrOfferMinimumNotMet, e.Minimum, e.Err)
+	}
+	return fmt.Sprintf("%v: %v", ErrOfferMinimumNotMet, e.Err)
+}
+
+func (e *OfferMinimumError) Unwrap() []error {
+	return []error{ErrOfferMinimumNotMet, e.Err}
+}
+
+// asOfferMinimumError converts a "minimum not met" API rejection into an
+// OfferMinimumError, returning nil for any other error
+func asOfferMinimumError(err error) *OfferMinimumError {
+	var bfxErr BitfinexError
+	if !errors.As(err, &bfxErr) {
+		return nil
+	}
+
+	msg := strings.ToLower(bfxErr.Message)
+	if !strings.Contains(msg, "minimum") {
+		return nil
+	}
+
+	minErr := &OfferMinimumError{Err: err}
+	if m := minimumAmountPattern.FindStringSubmatch(msg); m != nil {
+		minErr.Minimum, _ = strconv.ParseFloat(m[1], 64)
+	}
+	return minErr
+}
@@ -0,0 +1,44 @@
+package data
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClientErrorsMatchSentinels(t *testing.T) {
+	calls := []struct {
+		name string
+		call func(c *Client) error
+	}{
+		{"cancel", func(c *Client) error { _, err := c.CancelFundingOffer(41); return err }},
+		{"submit", func(c *Client) error {
+			_, err := c.SubmitFundingOffer(FundingOfferRequest{Symbol: "fUSD", Amount: "150", Rate: "0.0002", Period: 2})
+			return err
+		}},
+		{"offers", func(c *Client) error { _, err := c.GetActiveFundingOffers("fUSD"); return err }},
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"nonce", http.StatusInternalServerError, `["error",10114,"nonce: small"]`, ErrNonceTooSmall},
+		{"rate limit", http.StatusTooManyRequests, `["error",11010,"ratelimit: error"]`, ErrRateLimited},
+		{"api key", http.StatusInternalServerError, `["error",10100,"apikey: invalid"]`, ErrUnauthorized},
+	}
+
+	for _, call := range calls {
+		for _, tt := range tests {
+			t.Run(call.name+"/"+tt.name, func(t *testing.T) {
+				c := newTestClient(t, cannedDoer(tt.status, tt.body))
+				err := call.call(c)
+				if !errors.Is(err, tt.want) {
+					t.Errorf("err = %v, want errors.Is %v", err, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/gary/bitfinex-lending-bot/util.go"
+	"github.com/gorilla/websocket"
+)
+
+// Funding event types sent on the authenticated channel
+const (
+	FundingOfferSnapshot  = "fos" // Active offers on connect
+	FundingOfferNew       = "fon"
+	FundingOfferUpdate    = "fou"
+	FundingOfferClose     = "foc" // Offer filled or cancelled
+	FundingCreditSnapshot = "fcs" // Active credits on connect
+	FundingCreditNew      = "fcn" // Offer (partially) filled
+	FundingCreditUpdate   = "fcu"
+	FundingCreditClose    = "fcc" // Credit repaid or expired
+)
+
+// FundingUpdate is a funding offer or credit event from the authenticated
+// channel. Snapshots are delivered as one update per entry.
+type FundingUpdate struct {
+	Event  string         // One of the Funding* event types
+	Offer  *FundingOffer  // Set for offer events
+	Credit *FundingCredit // Set for credit events
+}
+
+// FundingSubscription represents an authenticated subscription to funding
+// offer and credit events
+type FundingSubscription struct {
+	conn      *websocket.Conn
+	done      chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+	onUpdate  func(FundingUpdate)
+	logger    util.Logger
+}
+
+// SubscribeToFunding opens an authenticated WebSocket and calls onUpdate for
+// every funding offer and credit event, so fills can be handled as they
+// happen instead of by polling
+func (c *Client) SubscribeToFunding(onUpdate func(FundingUpdate)) (*FundingSubscription, error) {
+	conn, err := c.dialAuthenticated("funding-offers", "funding-credits")
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &FundingSubscription{
+		conn:     conn,
+		done:     make(chan struct{}),
+		closed:   make(chan struct{}),
+		onUpdate: onUpdate,
+		logger:   c.log(),
+	}
+
+	// Start listening goroutine
+	go sub.listen()
+
+	return sub, nil
+}
+
+// listen listens for funding messages on the authenticated channel
+func (s *FundingSubscription) listen() {
+	defer close(s.closed)
+	defer s.Close()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+			_, message, err := s.conn.ReadMessage()
+			if err != nil {
+				// A read failing because Close shut the connection is expected
+				select {
+				case <-s.done:
+				default:
+					s.logger.Errorf("Error reading message: %v", err)
+				}
+				return
+			}
+
+			// Event messages (auth result, info) are objects, data messages are arrays
+			var msg []interface{}
+			if err := json.Unmarshal(message, &msg); err != nil {
+				continue
+			}
+			if len(msg) < 3 {
+				continue
+			}
+
+			event, _ := util.FieldString(msg, 1)
+			payload, ok := msg[2].([]interface{})
+			if !ok {
+				continue
+			}
+
+			switch event {
+			case FundingOfferSnapshot, FundingCreditSnapshot:
+				for _, raw := range payload {
+					if arr, ok := raw.([]interface{}); ok {
+						s.dispatch(event, arr)
+					}
+				}
+			case FundingOfferNew, FundingOfferUpdate, FundingOfferClose,
+				FundingCreditNew, FundingCreditUpdate, FundingCreditClose:
+				s.dispatch(event, payload)
+			}
+		}
+	}
+}
+
+// dispatch parses a single offer or credit entry and forwards it
+func (s *FundingSubscription) dispatch(event string, raw []interface{}) {
+	update := FundingUpdate{Event: event}
+
+	switch event {
+	case FundingOfferSnapshot, FundingOfferNew, FundingOfferUpdate, FundingOfferClose:
+		offer, ok := parseFundingOfferArray(raw)
+		if !ok {
+			return
+		}
+		update.Offer = &offer
+	default:
+		credit, ok := parseFundingCreditArray(raw)
+		if !ok {
+			return
+		}
+		update.Credit = &credit
+	}
+
+	s.onUpdate(update)
+}
+
+// Done returns a channel that is closed once the subscription stops
+func (s *FundingSubscription) Done() <-chan struct{} {
+	return s.closed
+}
+
+// Close closes the subscription. It is safe to call more than once and from
+// multiple goroutines.
+func (s *FundingSubscription) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		// Unblock a pending read in listen
+		s.conn.Close()
+	})
+}
@@ -0,0 +1,31 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Ping checks that the API is reachable and the credentials are accepted by
+// making a lightweight authenticated call. A network failure matches
+// ErrUnreachable and rejected credentials match ErrUnauthorized.
+func (c *Client) Ping() error {
+	return c.PingContext(context.Background())
+}
+
+// PingContext is like Ping but honors ctx for cancellation
+func (c *Client) PingContext(ctx context.Context) error {
+	_, err := c.SendRequestContext(ctx, "POST", "v2/auth/r/wallets", nil)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	var bfxErr BitfinexError
+	if !errors.As(err, &bfxErr) {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	return err
+}
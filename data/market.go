@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// MarketState is a snapshot of the account and market for one funding symbol
+type MarketState struct {
+	Symbol            string          // Funding symbol, e.g. fUSD
+	TotalBalance      float64         // Funding wallet balance
+	AvailableBalance  float64         // Funding wallet balance not lent or offered
+	UnsettledInterest float64         // Interest earned but not yet paid into the balance
+	ActiveOffers      []FundingOffer  // Open offers
+	Credits           []FundingCredit // Funds currently lent; nil if unavailable
+	Book              []BitfinexOffer // Raw funding book; nil if unavailable
+	Stats             []FundingStat   // Funding statistics, newest first; nil if unavailable
+
+	// Fetch errors of the optional fields above; the field is nil when its
+	// error is set
+	ActiveOffersErr error
+	CreditsErr      error
+	BookErr         error
+	StatsErr        error
+}
+
+// GetMarketState fetches the wallet balances, active offers, credits, raw
+// funding book and funding statistics of symbol in one call. Only a wallet
+// failure fails the call; other failures are reported in the matching Err
+// field.
+func (c *Client) GetMarketState(symbol string) (*MarketState, error) {
+	return c.GetMarketStateContext(context.Background(), symbol)
+}
+
+// GetMarketStateContext is like GetMarketState but honors ctx for cancellation
+func (c *Client) GetMarketStateContext(ctx context.Context, symbol string) (*MarketState, error) {
+	return c.getMarketState(ctx, symbol, nil, true)
+}
+
+// GetMarketStateWithWallets is like GetMarketState but takes the balances
+// from wallets, as returned by GetWalletsDetailed, instead of fetching them.
+// It lets a caller check the balance before paying for the other requests.
+func (c *Client) GetMarketStateWithWallets(symbol string, wallets []Wallet) *MarketState {
+	return c.GetMarketStateWithWalletsContext(context.Background(), symbol, wallets)
+}
+
+// GetMarketStateWithWalletsContext is like GetMarketStateWithWallets but honors ctx for cancellation
+func (c *Client) GetMarketStateWithWalletsContext(ctx context.Context, symbol string, wallets []Wallet) *MarketState {
+	state, _ := c.getMarketState(ctx, symbol, wallets, false)
+	return state
+}
+
+// getMarketState builds the market state of symbol, fetching the wallets
+// when fetchWallets is set
+func (c *Client) getMarketState(ctx context.Context, symbol string, wallets []Wallet, fetchWallets bool) (*MarketState, error) {
+	state := &MarketState{Symbol: symbol}
+
+	var (
+		wg         sync.WaitGroup
+		walletsErr error
+	)
+
+	// Public endpoints run concurrently. Authenticated ones run in sequence
+	// because Bitfinex rejects nonces that arrive out of order.
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		if fetchWallets {
+			wallets, walletsErr = c.GetWalletsDetailedContext(ctx)
+		}
+		state.ActiveOffers, state.ActiveOffersErr = c.GetActiveFundingOffersContext(ctx, symbol)
+		state.Credits, state.CreditsErr = c.GetFundingCreditsContext(ctx, symbol)
+	}()
+	go func() {
+		defer wg.Done()
+		state.Book, state.BookErr = c.GetFundingBookContext(ctx, symbol, "R0", 100)
+	}()
+	go func() {
+		defer wg.Done()
+		state.Stats, state.StatsErr = c.GetFundingStatContext(ctx, symbol)
+	}()
+	wg.Wait()
+
+	if walletsErr != nil {
+		return nil, walletsErr
+	}
+
+	if w, ok := FundingWallet(wallets, symbol); ok {
+		state.TotalBalance = w.Balance
+		state.AvailableBalance = w.AvailableBalance
+		state.UnsettledInterest = w.UnsettledInterest
+	}
+
+	return state, nil
+}
+
+// FundingWallet returns the funding wallet of the currency of symbol
+// (fUSD or USD) among wallets
+func FundingWallet(wallets []Wallet, symbol string) (Wallet, bool) {
+	currency := strings.TrimPrefix(symbol, "f")
+	for _, w := range wallets {
+		if w.Type == "funding" && w.Currency == currency {
+			return w, true
+		}
+	}
+	return Wallet{}, false
+}
+
+// Currency returns the currency of the funding symbol, e.g. USD for fUSD
+func (m MarketState) Currency() string {
+	return strings.TrimPrefix(m.Symbol, "f")
+}
+
+// Offered returns the amount held in open offers
+func (m MarketState) Offered() float64 {
+	var offered float64
+	for _, offer := range m.ActiveOffers {
+		offered += offer.Amount
+	}
+	return offered
+}
+
+// Lent returns the amount currently lent out. When credits are unavailable
+// it is estimated from the wallet balances.
+func (m MarketState) Lent() float64 {
+	if m.Credits == nil {
+		return m.TotalBalance - m.AvailableBalance - m.Offered()
+	}
+
+	var lent float64
+	for _, credit := range m.Credits {
+		lent += credit.Amount
+	}
+	return lent
+}
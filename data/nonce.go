@@ -0,0 +1,43 @@
+package data
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// lastNonce is the most recent nonce handed out by monotonicNonce; it is
+// shared by all clients since Bitfinex tracks nonces per API key
+var lastNonce atomic.Int64
+
+// monotonicNonce returns the current millisecond timestamp, bumped as needed
+// so that every call returns a strictly larger value, even when called
+// concurrently within the same millisecond
+func monotonicNonce() int64 {
+	for {
+		last := lastNonce.Load()
+		next := time.Now().UnixMilli()
+		if next <= last {
+			next = last + 1
+		}
+		if lastNonce.CompareAndSwap(last, next) {
+			return next
+		}
+	}
+}
+
+// WithNonceSource replaces the nonce generator, e.g. with a deterministic
+// counter in tests. The source must return strictly increasing values.
+func WithNonceSource(next func() int64) ClientOption {
+	return func(c *Client) {
+		c.nonce = next
+	}
+}
+
+// Nonce returns the next request nonce
+func (c *Client) Nonce() string {
+	if c.nonce == nil {
+		return strconv.FormatInt(monotonicNonce(), 10)
+	}
+	return strconv.FormatInt(c.nonce(), 10)
+}
@@ -0,0 +1,92 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// OfferBuilder assembles a FundingOfferRequest from typed values, e.g.
+//
+//	req, err := data.NewOfferBuilder("fUSD").Amount(500).Rate(0.0002).Period(2).Build()
+//
+// There is no Renew method: Bitfinex has no per-offer renew flag, and
+// auto-renew is configured per currency with Client.SetFundingAutoRenew.
+type OfferBuilder struct {
+	req       FundingOfferRequest
+	amount    float64
+	rate      float64
+	amountSet bool
+	rateSet   bool
+	periodSet bool
+}
+
+// NewOfferBuilder starts a LIMIT offer for symbol
+func NewOfferBuilder(symbol string) *OfferBuilder {
+	return &OfferBuilder{req: FundingOfferRequest{Type: OfferTypeLimit, Symbol: symbol}}
+}
+
+// Amount sets the amount to offer
+func (b *OfferBuilder) Amount(amount float64) *OfferBuilder {
+	b.amount = amount
+	b.amountSet = true
+	return b
+}
+
+// Rate sets the daily rate, or the delta to FRR for FRRDELTA types
+func (b *OfferBuilder) Rate(rate float64) *OfferBuilder {
+	b.rate = rate
+	b.rateSet = true
+	return b
+}
+
+// Period sets the offer period in days
+func (b *OfferBuilder) Period(days int) *OfferBuilder {
+	b.req.Period = days
+	b.periodSet = true
+	return b
+}
+
+// Type sets the offer type, one of the OfferType* constants
+func (b *OfferBuilder) Type(offerType string) *OfferBuilder {
+	b.req.Type = offerType
+	return b
+}
+
+// Flags replaces the offer flags
+func (b *OfferBuilder) Flags(flags FundingFlags) *OfferBuilder {
+	b.req.Flags = int(flags)
+	return b
+}
+
+// Hidden keeps the offer out of the public funding book
+func (b *OfferBuilder) Hidden(hidden bool) *OfferBuilder {
+	flags := FundingFlags(b.req.Flags)
+	if hidden {
+		flags = flags.With(FundingFlagHidden)
+	} else {
+		flags = flags.Without(FundingFlagHidden)
+	}
+	b.req.Flags = int(flags)
+	return b
+}
+
+// Build formats the amount and rate for the API and validates the request
+func (b *OfferBuilder) Build() (FundingOfferRequest, error) {
+	switch {
+	case !b.amountSet:
+		return FundingOfferRequest{}, fmt.Errorf("amount is required")
+	case !b.rateSet:
+		return FundingOfferRequest{}, fmt.Errorf("rate is required")
+	case !b.periodSet:
+		return FundingOfferRequest{}, fmt.Errorf("period is required")
+	}
+
+	req := b.req
+	req.Amount = util.FormatAmount(b.amount, CurrencyPrecision(req.Symbol))
+	req.Rate = util.FormatRate(b.rate)
+	if err := req.Validate(); err != nil {
+		return FundingOfferRequest{}, err
+	}
+	return req, nil
+}
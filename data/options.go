@@ -0,0 +1,185 @@
+package data
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// ClientOption configures optional Client behavior
+type ClientOption func(*Client)
+
+// WithRandSource sets the source used for all randomness in the client.
+// Affected behaviors: request jitter (Jitter) and any randomized
+// tie-breaking. Pin a seeded source in tests for reproducible results.
+func WithRandSource(src rand.Source) ClientOption {
+	return func(c *Client) {
+		c.rng = newLockedRand(src)
+	}
+}
+
+// WithRandSeed is a shorthand for WithRandSource(rand.NewSource(seed))
+func WithRandSeed(seed int64) ClientOption {
+	return WithRandSource(rand.NewSource(seed))
+}
+
+// lockedRand wraps rand.Rand so it can be shared between goroutines
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand(src rand.Source) *lockedRand {
+	return &lockedRand{r: rand.New(src)}
+}
+
+// Float64 returns a pseudo-random number in [0.0, 1.0)
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+// Int63n returns a pseudo-random number in [0, n)
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
+
+// Jitter returns a random duration in [0, max) drawn from the client's
+// random source
+func (c *Client) Jitter(max time.Duration) time.Duration {
+	if max <= 0 || c.rng == nil {
+		return 0
+	}
+	return time.Duration(c.rng.Int63n(int64(max)))
+}
+
+// WithBaseURL overrides the REST API base URL. The URL is validated and
+// normalized by NewClient.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.BaseURL = baseURL
+	}
+}
+
+// WithWSPublicURL overrides the public WebSocket URL
+func WithWSPublicURL(wsURL string) ClientOption {
+	return func(c *Client) {
+		c.WSPublicURL = wsURL
+	}
+}
+
+// WithWSAuthURL overrides the authenticated WebSocket URL
+func WithWSAuthURL(wsURL string) ClientOption {
+	return func(c *Client) {
+		c.WSAuthURL = wsURL
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used for REST requests, e.g. to
+// route through a proxy or a mock round tripper in tests. hc is not
+// modified: WithTimeout and WithTransport apply to a copy of it, in any
+// option order.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.HTTPClient = hc
+	}
+}
+
+// WithTimeout sets the overall timeout of each HTTP request (default 10s)
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = timeout
+		c.timeoutSet = true
+	}
+}
+
+// WithTransport sets the HTTP transport, replacing the default connection
+// pool settings
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+// Doer executes HTTP requests. *http.Client implements it; tests can supply
+// a stub returning canned Bitfinex responses.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// WithDoer routes all REST requests through d instead of HTTPClient.
+// WithTimeout and WithTransport have no effect on d.
+func WithDoer(d Doer) ClientOption {
+	return func(c *Client) {
+		c.doer = d
+	}
+}
+
+// do executes a REST request with the configured Doer, falling back to
+// HTTPClient
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.doer != nil {
+		return c.doer.Do(req)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+// Close releases the idle keep-alive connections held by the client's HTTP
+// transport and Doer and logs how often each throttled retry message was
+// suppressed. The client stays usable; new requests open new connections.
+// Rate limiters and loggers hold no background resources.
+func (c *Client) Close() error {
+	if c.retryLog != nil {
+		c.retryLog.Flush()
+	}
+	if c.HTTPClient != nil {
+		c.HTTPClient.CloseIdleConnections()
+	}
+	if closer, ok := c.doer.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+	return nil
+}
+
+// WithLogger sets the destination of the client's log output. Pass
+// util.NopLogger{} to silence it.
+func WithLogger(l util.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithRetryLogWindow sets how long an identical retry message is
+// suppressed after being logged; the number of suppressed repeats is
+// logged once the window has passed or the client is closed. The default
+// is one minute.
+func WithRetryLogWindow(window time.Duration) ClientOption {
+	return func(c *Client) {
+		c.retryLogWindow = window
+	}
+}
+
+// log returns the client's logger, falling back to the standard logger for
+// clients not built with NewClient
+func (c *Client) log() util.Logger {
+	if c.logger == nil {
+		return util.NewStdLogger(nil)
+	}
+	return c.logger
+}
+
+// logRetry reports a retried request, throttled when the client was built
+// with NewClient
+func (c *Client) logRetry(format string, v ...interface{}) {
+	if c.retryLog == nil {
+		c.log().Warnf(format, v...)
+		return
+	}
+	c.retryLog.Printf(format, v...)
+}
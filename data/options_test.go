@@ -0,0 +1,110 @@
+package data
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// recordingLogger keeps the warnings written to it
+type recordingLogger struct {
+	util.NopLogger
+	warnings []string
+}
+
+func (l *recordingLogger) Warnf(format string, v ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, v...))
+}
+
+func TestRetryLogWindow(t *testing.T) {
+	tests := []struct {
+		name         string
+		window       time.Duration
+		wantWarnings int // Retry warnings before Close
+		wantSummary  bool
+	}{
+		{"repeats suppressed", time.Hour, 1, true},
+		{"no window", 0, 6, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &recordingLogger{}
+			c := newTestClient(t, cannedDoer(http.StatusServiceUnavailable, `["error",20060,"maintenance"]`),
+				WithLogger(log), WithRetryLogWindow(tt.window))
+			c.MaxRetries = 3
+			c.RetryBackoff = time.Millisecond
+
+			for i := 0; i < 2; i++ {
+				if _, err := c.SendPublicRequest("GET", "v2/platform/status"); err == nil {
+					t.Fatal("request succeeded, want an error")
+				}
+			}
+			if len(log.warnings) != tt.wantWarnings {
+				t.Errorf("%d warnings before Close, want %d: %v", len(log.warnings), tt.wantWarnings, log.warnings)
+			}
+
+			log.warnings = nil
+			c.Close()
+			gotSummary := len(log.warnings) == 1 && strings.HasSuffix(log.warnings[0], "(repeated 5 times)")
+			if gotSummary != tt.wantSummary || (!tt.wantSummary && len(log.warnings) != 0) {
+				t.Errorf("Close wrote %v, want summary %v", log.warnings, tt.wantSummary)
+			}
+		})
+	}
+}
+
+func TestHTTPClientOptions(t *testing.T) {
+	transport := &http.Transport{}
+
+	tests := []struct {
+		name          string
+		opts          func(shared *http.Client) []ClientOption
+		wantErr       bool
+		wantTimeout   time.Duration
+		wantTransport http.RoundTripper // nil to skip the check
+	}{
+		{"defaults", func(*http.Client) []ClientOption { return nil }, false, 10 * time.Second, nil},
+		{"timeout and transport", func(*http.Client) []ClientOption {
+			return []ClientOption{WithTimeout(time.Minute), WithTransport(transport)}
+		}, false, time.Minute, transport},
+		{"timeout after shared client", func(hc *http.Client) []ClientOption {
+			return []ClientOption{WithHTTPClient(hc), WithTimeout(time.Minute)}
+		}, false, time.Minute, http.DefaultTransport},
+		{"timeout before shared client", func(hc *http.Client) []ClientOption {
+			return []ClientOption{WithTimeout(time.Minute), WithTransport(transport), WithHTTPClient(hc)}
+		}, false, time.Minute, transport},
+		{"shared client alone", func(hc *http.Client) []ClientOption {
+			return []ClientOption{WithHTTPClient(hc)}
+		}, false, 5 * time.Second, http.DefaultTransport},
+		{"nil client", func(*http.Client) []ClientOption {
+			return []ClientOption{WithHTTPClient(nil), WithTimeout(time.Minute)}
+		}, true, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shared := &http.Client{Timeout: 5 * time.Second, Transport: http.DefaultTransport}
+			c, err := NewClientWithOptions("key", "secret", tt.opts(shared)...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if shared.Timeout != 5*time.Second || shared.Transport != http.DefaultTransport {
+				t.Errorf("shared client modified: %+v", shared)
+			}
+			if tt.wantErr {
+				return
+			}
+			if c.HTTPClient.Timeout != tt.wantTimeout {
+				t.Errorf("Timeout = %v, want %v", c.HTTPClient.Timeout, tt.wantTimeout)
+			}
+			if tt.wantTransport != nil && c.HTTPClient.Transport != tt.wantTransport {
+				t.Errorf("Transport = %v, want %v", c.HTTPClient.Transport, tt.wantTransport)
+			}
+		})
+	}
+}
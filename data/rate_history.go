@@ -0,0 +1,104 @@
+package data
+
+import (
+	"sync"
+	"time"
+)
+
+// RatePoint is a rate observation recorded by the client
+type RatePoint struct {
+	Time time.Time // Observation time
+	FRR  float64   // Flash Return Rate (daily)
+	Bid  float64   // Best bid rate (daily), 0 when not observed
+	Ask  float64   // Best ask rate (daily), 0 when not observed
+}
+
+// rateHistory keeps the most recent rate points per symbol in fixed-size
+// ring buffers
+type rateHistory struct {
+	mu      sync.Mutex
+	size    int
+	symbols map[string]*rateRing
+}
+
+// rateRing is a ring buffer of rate points; next is where the next point is
+// written once the buffer is full
+type rateRing struct {
+	points []RatePoint
+	next   int
+}
+
+// WithRateHistory makes the client record the FRR and best rates returned
+// by GetFundingStat and GetFundingTicker, keeping the last size points per
+// symbol for RecentRates. History is off unless this option is set.
+func WithRateHistory(size int) ClientOption {
+	return func(c *Client) {
+		if size <= 0 {
+			c.rates = nil
+			return
+		}
+		c.rates = &rateHistory{size: size, symbols: make(map[string]*rateRing)}
+	}
+}
+
+// RecentRates returns the recorded rate points of symbol, oldest first. It
+// returns nil when rate history is off or nothing was recorded.
+func (c *Client) RecentRates(symbol string) []RatePoint {
+	if c.rates == nil {
+		return nil
+	}
+	return c.rates.recent(symbol)
+}
+
+// recordRate adds point to the history of symbol, if history is on
+func (c *Client) recordRate(symbol string, point RatePoint) {
+	if c.rates == nil {
+		return
+	}
+	c.rates.add(symbol, point)
+}
+
+func (h *rateHistory) add(symbol string, point RatePoint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ring, ok := h.symbols[symbol]
+	if !ok {
+		ring = &rateRing{points: make([]RatePoint, 0, h.size)}
+		h.symbols[symbol] = ring
+	}
+
+	// Polling returns the same stats entry until a new one is published
+	if last := ring.last(); last != nil && *last == point {
+		return
+	}
+
+	if len(ring.points) < h.size {
+		ring.points = append(ring.points, point)
+		return
+	}
+	ring.points[ring.next] = point
+	ring.next = (ring.next + 1) % h.size
+}
+
+func (h *rateHistory) recent(symbol string) []RatePoint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ring, ok := h.symbols[symbol]
+	if !ok {
+		return nil
+	}
+
+	points := make([]RatePoint, 0, len(ring.points))
+	points = append(points, ring.points[ring.next:]...)
+	return append(points, ring.points[:ring.next]...)
+}
+
+// last returns the newest point, or nil when the ring is empty
+func (r *rateRing) last() *RatePoint {
+	if len(r.points) == 0 {
+		return nil
+	}
+	return &r.points[(r.next+len(r.points)-1)%len(r.points)]
+}
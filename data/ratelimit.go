@@ -0,0 +1,45 @@
+package data
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultRequestsPerMinute stays below the tightest Bitfinex REST limits
+const defaultRequestsPerMinute = 60
+
+// newPerMinuteLimiter creates a token bucket allowing perMinute requests a
+// minute with a small burst; perMinute <= 0 disables limiting
+func newPerMinuteLimiter(perMinute int) *rate.Limiter {
+	if perMinute <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	burst := perMinute / 10
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), burst)
+}
+
+// WithRateLimit sets the per-minute request limits for authenticated and
+// public endpoints, which Bitfinex limits separately. Each client has its
+// own buckets. A limit <= 0 disables throttling for that kind of endpoint.
+func WithRateLimit(authPerMinute, publicPerMinute int) ClientOption {
+	return func(c *Client) {
+		c.authLimiter = newPerMinuteLimiter(authPerMinute)
+		c.publicLimiter = newPerMinuteLimiter(publicPerMinute)
+	}
+}
+
+// limiterFor returns the limiter that applies to path
+func (c *Client) limiterFor(path string) *rate.Limiter {
+	limiter := c.publicLimiter
+	if isAuthPath(path) {
+		limiter = c.authLimiter
+	}
+	if limiter == nil {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return limiter
+}
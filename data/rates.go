@@ -0,0 +1,20 @@
+package data
+
+// Rate convention: every rate in this package (offers, credits, book
+// entries, FRR) is a daily rate as a decimal, e.g. 0.0002 for 0.02% a day.
+// Annual rates are simple, not compounded, and also decimal, e.g. 0.073 for
+// 7.3% a year. Convert with DailyToAnnual and AnnualToDaily and only compare
+// rates of the same kind.
+
+// DaysPerYear is the number of days used to annualize daily rates
+const DaysPerYear = 365
+
+// DailyToAnnual converts a daily rate into a simple annual rate
+func DailyToAnnual(daily float64) float64 {
+	return daily * DaysPerYear
+}
+
+// AnnualToDaily converts a simple annual rate into a daily rate
+func AnnualToDaily(annual float64) float64 {
+	return annual / DaysPerYear
+}
@@ -0,0 +1,146 @@
+package data
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/gary/bitfinex-lending-bot/util.go"
+	"github.com/gorilla/websocket"
+)
+
+// WalletSubscription represents an authenticated subscription to funding
+// wallet snapshots ("ws") and updates ("wu")
+type WalletSubscription struct {
+	conn      *websocket.Conn
+	done      chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+	onUpdate  func(Wallet)
+	logger    util.Logger
+}
+
+// SubscribeWallets opens an authenticated WebSocket and calls onUpdate for
+// every funding wallet in snapshot and update events
+func (c *Client) SubscribeWallets(onUpdate func(Wallet)) (*WalletSubscription, error) {
+	conn, err := c.dialAuthenticated("wallet")
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &WalletSubscription{
+		conn:     conn,
+		done:     make(chan struct{}),
+		closed:   make(chan struct{}),
+		onUpdate: onUpdate,
+		logger:   c.log(),
+	}
+
+	// Start listening goroutine
+	go sub.listen()
+
+	return sub, nil
+}
+
+// listen listens for wallet messages on the authenticated channel
+func (s *WalletSubscription) listen() {
+	defer close(s.closed)
+	defer s.Close()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+			_, message, err := s.conn.ReadMessage()
+			if err != nil {
+				// A read failing because Close shut the connection is expected
+				select {
+				case <-s.done:
+				default:
+					s.logger.Errorf("Error reading message: %v", err)
+				}
+				return
+			}
+
+			// Event messages (auth result, info) are objects, data messages are arrays
+			var msg []interface{}
+			if err := json.Unmarshal(message, &msg); err != nil {
+				continue
+			}
+			if len(msg) < 3 {
+				continue
+			}
+
+			switch msg[1] {
+			case "ws":
+				snapshot, ok := msg[2].([]interface{})
+				if !ok {
+					continue
+				}
+				for _, raw := range snapshot {
+					if arr, ok := raw.([]interface{}); ok {
+						s.dispatch(arr)
+					}
+				}
+			case "wu":
+				if arr, ok := msg[2].([]interface{}); ok {
+					s.dispatch(arr)
+				}
+			}
+		}
+	}
+}
+
+// dispatch forwards a funding wallet entry to the callback
+func (s *WalletSubscription) dispatch(raw []interface{}) {
+	wallet, ok := parseWalletArray(raw)
+	if !ok || wallet.Type != "funding" {
+		return
+	}
+	s.onUpdate(wallet)
+}
+
+// Done returns a channel that is closed once the subscription stops
+func (s *WalletSubscription) Done() <-chan struct{} {
+	return s.closed
+}
+
+// Close closes the subscription. It is safe to call more than once and from
+// multiple goroutines.
+func (s *WalletSubscription) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		// Unblock a pending read in listen
+		s.conn.Close()
+	})
+}
+
+// parseWalletArray converts a Bitfinex wallet array into a Wallet
+// Bitfinex API returns format:
+// [WALLET_TYPE, CURRENCY, BALANCE, UNSETTLED_INTEREST, AVAILABLE_BALANCE, LAST_CHANGE, TRADE_DETAILS]
+func parseWalletArray(raw []interface{}) (Wallet, bool) {
+	if len(raw) < 3 {
+		return Wallet{}, false
+	}
+
+	walletType, okType := util.FieldString(raw, 0)
+	currency, okCurrency := util.FieldString(raw, 1)
+	balance, okBalance := util.FieldFloat(raw, 2)
+	if !okType || !okCurrency || !okBalance {
+		return Wallet{}, false
+	}
+
+	wallet := Wallet{
+		Type:     walletType,
+		Currency: currency,
+		Balance:  balance,
+	}
+	wallet.UnsettledInterest, _ = util.FieldFloat(raw, 3)
+	wallet.AvailableBalance, _ = util.FieldFloat(raw, 4)
+	wallet.LastChange, _ = util.FieldString(raw, 5)
+	if len(raw) > 6 {
+		wallet.LastChangeMetadata, _ = raw[6].(map[string]interface{})
+	}
+
+	return wallet, true
+}
@@ -0,0 +1,39 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialAuthenticated opens a WebSocket to WSAuthURL and sends the auth event.
+// filter limits the account events Bitfinex sends (e.g. "wallet",
+// "funding-offers"); none means all of them.
+func (c *Client) dialAuthenticated(filter ...string) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(c.WSAuthURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connection error: %w", err)
+	}
+
+	// Build authentication message
+	nonce := c.Nonce()
+	payload := "AUTH" + nonce
+
+	msg := map[string]interface{}{
+		"event":       "auth",
+		"apiKey":      c.APIKey,
+		"authSig":     sign(c.APISecret, payload),
+		"authPayload": payload,
+		"authNonce":   nonce,
+	}
+	if len(filter) > 0 {
+		msg["filter"] = filter
+	}
+
+	if err := conn.WriteJSON(msg); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error sending auth message: %w", err)
+	}
+
+	return conn, nil
+}
@@ -5,4 +5,5 @@ go 1.22.6
 require (
 	github.com/gorilla/websocket v1.4.2
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/time v0.5.0
 )
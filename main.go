@@ -1,11 +1,34 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/gary/bitfinex-lending-bot/strategy"
 )
 
 func main() {
-	for {
-		strategy.StrategyManager()
+	// BITFINEX_CONFIG names a JSON config file to use instead of .env
+	cfg, err := strategy.LoadConfig(os.Getenv("BITFINEX_CONFIG"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Print the effective configuration and exit
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		fmt.Println(cfg)
+		return
+	}
+
+	// Stop the strategy loop cleanly on Ctrl+C or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := strategy.StrategyManager(ctx, cfg); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,179 @@
+// Package metrics exposes the bot's counters and gauges in the Prometheus
+// text format. It implements the small subset of the format the bot needs
+// so no client library dependency is required.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bot metrics, updated by the strategy runner
+var (
+	OffersPlaced    = NewCounter("bitfinex_bot_offers_placed_total", "Funding offers placed.")
+	OffersCancelled = NewCounter("bitfinex_bot_offers_cancelled_total", "Funding offers cancelled.")
+	OffersFilled    = NewCounter("bitfinex_bot_offers_filled_total", "Funding offers filled.")
+	APIErrors       = NewCounterVec("bitfinex_bot_api_errors_total", "Failed API calls by Bitfinex error code.", "code")
+	DeployedCapital = NewGaugeVec("bitfinex_bot_deployed_capital", "Capital lent or offered, in native units.", "symbol")
+	CycleDuration   = NewGauge("bitfinex_bot_cycle_duration_seconds", "Duration of the last strategy cycle.")
+)
+
+// metric is a named family of samples, keyed by label value ("" when the
+// metric has no label)
+type metric struct {
+	name  string
+	help  string
+	kind  string // counter or gauge
+	label string // Label name, empty for unlabelled metrics
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*metric
+)
+
+func register(name, help, kind, label string) *metric {
+	m := &metric{name: name, help: help, kind: kind, label: label, values: make(map[string]float64)}
+	if label == "" {
+		m.values[""] = 0
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+	return m
+}
+
+func (m *metric) add(labelValue string, v float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[labelValue] += v
+}
+
+func (m *metric) set(labelValue string, v float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[labelValue] = v
+}
+
+// Counter is a value that only goes up
+type Counter struct{ m *metric }
+
+// NewCounter registers a counter
+func NewCounter(name, help string) Counter {
+	return Counter{register(name, help, "counter", "")}
+}
+
+// Inc adds 1 to the counter
+func (c Counter) Inc() { c.m.add("", 1) }
+
+// Add adds v, which must not be negative, to the counter
+func (c Counter) Add(v float64) { c.m.add("", v) }
+
+// CounterVec is a counter partitioned by one label
+type CounterVec struct{ m *metric }
+
+// NewCounterVec registers a counter with the given label name
+func NewCounterVec(name, help, label string) CounterVec {
+	return CounterVec{register(name, help, "counter", label)}
+}
+
+// Inc adds 1 to the counter for labelValue
+func (c CounterVec) Inc(labelValue string) { c.m.add(labelValue, 1) }
+
+// Gauge is a value that can go up and down
+type Gauge struct{ m *metric }
+
+// NewGauge registers a gauge
+func NewGauge(name, help string) Gauge {
+	return Gauge{register(name, help, "gauge", "")}
+}
+
+// Set sets the gauge to v
+func (g Gauge) Set(v float64) { g.m.set("", v) }
+
+// GaugeVec is a gauge partitioned by one label
+type GaugeVec struct{ m *metric }
+
+// NewGaugeVec registers a gauge with the given label name
+func NewGaugeVec(name, help, label string) GaugeVec {
+	return GaugeVec{register(name, help, "gauge", label)}
+}
+
+// Set sets the gauge for labelValue to v
+func (g GaugeVec) Set(labelValue string, v float64) { g.m.set(labelValue, v) }
+
+// WriteText writes every registered metric in the Prometheus text format
+func WriteText(w io.Writer) error {
+	registryMu.Lock()
+	metrics := append([]*metric{}, registry...)
+	registryMu.Unlock()
+
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+
+		m.mu.Lock()
+		labels := make([]string, 0, len(m.values))
+		for labelValue := range m.values {
+			labels = append(labels, labelValue)
+		}
+		sort.Strings(labels)
+		for _, labelValue := range labels {
+			value := strconv.FormatFloat(m.values[labelValue], 'g', -1, 64)
+			if m.label == "" {
+				fmt.Fprintf(&b, "%s %s\n", m.name, value)
+			} else {
+				fmt.Fprintf(&b, "%s{%s=\"%s\"} %s\n", m.name, m.label, escapeLabel(labelValue), value)
+			}
+		}
+		m.mu.Unlock()
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabel escapes a label value as the text format requires
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// Handler serves the registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteText(w)
+	})
+}
+
+// ListenAndServe serves the metrics on addr at /metrics until ctx is
+// cancelled
+func ListenAndServe(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+	return nil
+}
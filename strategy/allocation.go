@@ -0,0 +1,79 @@
+package strategy
+
+import (
+	"math"
+
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// AllocationPlan holds how much new capital to offer in each bucket
+type AllocationPlan struct {
+	Fix     float64 // Amount to offer as fixed lending
+	Predict float64 // Amount to offer as predictive lending
+}
+
+// Allocate splits free capital between the fixed and predictive buckets.
+// total is the funding wallet balance, available the free balance, lent the
+// capital working in active credits and offered the capital sitting in open
+// offers. The dist.Reserve share of total is left unplanned; lent and
+// offered count against the two lending buckets in proportion to their
+// ratios. When available cannot cover both buckets it is split between them
+// in proportion to what each requires. A bucket below minAmount is folded
+// into the other one so small free balances are not left idle; if the
+// combined amount is still below minAmount nothing is planned. Amounts are
+// computed in whole cents and rounded down, so the plan never sums to more
+// than available.
+func Allocate(total, available, lent, offered float64, dist Distribution, minAmount float64) AllocationPlan {
+	return AllocateDecimals(total, available, lent, offered, dist, minAmount, 2)
+}
+
+// AllocateDecimals is like Allocate for a currency whose amounts have
+// decimals places, e.g. data.CurrencyPrecision of the symbol, instead of
+// cents
+func AllocateDecimals(total, available, lent, offered float64, dist Distribution, minAmount float64, decimals int) AllocationPlan {
+	// Plan in whole units so rounding can never plan more than is free
+	free := util.ToUnits(available, decimals)
+	minUnits := util.ToUnits(minAmount, decimals)
+
+	// The reserve share of total is never lent
+	var fix, predict int64
+	if lendable := dist.Fix + dist.Predict; lendable > 0 {
+		uncommitted := util.ToUnits(total*lendable, decimals) - util.ToUnits(lent+offered, decimals)
+		fix = int64(math.Floor(float64(uncommitted) * dist.Fix / lendable))
+		predict = int64(math.Floor(float64(uncommitted) * dist.Predict / lendable))
+	}
+	if fix < 0 {
+		fix = 0
+	}
+	if predict < 0 {
+		predict = 0
+	}
+
+	// Never plan more than is free; when free capital is short, both
+	// buckets shrink by the same factor so the split is preserved
+	if free < 0 {
+		free = 0
+	}
+	if required := fix + predict; required > free {
+		fix = int64(math.Floor(float64(fix) * float64(free) / float64(required)))
+		predict = free - fix
+	}
+
+	// Fold undersized buckets into the other one
+	if fix < minUnits {
+		predict += fix
+		fix = 0
+	}
+	if predict < minUnits {
+		fix += predict
+		predict = 0
+	}
+	if fix < minUnits {
+		fix = 0
+	}
+
+	return AllocationPlan{
+		Fix:     util.FromUnits(fix, decimals),
+		Predict: util.FromUnits(predict, decimals),
+	}
+}
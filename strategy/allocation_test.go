@@ -0,0 +1,145 @@
+package strategy
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+func TestAllocateShortAvailableBalance(t *testing.T) {
+	half := Distribution{Fix: 0.5, Predict: 0.5}
+
+	tests := []struct {
+		name        string
+		total       float64
+		available   float64
+		lent        float64
+		dist        Distribution
+		wantFix     float64
+		wantPredict float64
+	}{
+		{"enough for both", 1000, 1000, 0, half, 500, 500},
+		{"short balance keeps the split", 1000, 600, 0, half, 300, 300},
+		{"short balance keeps an uneven split", 2000, 1000, 0, Distribution{Fix: 0.8, Predict: 0.2}, 800, 200},
+		{"short after lending", 2000, 400, 1000, half, 200, 200},
+		{"undersized bucket is folded", 1000, 200, 0, half, 0, 200},
+		{"below the minimum", 1000, 100, 0, half, 0, 0},
+		{"nothing free", 1000, 0, 0, half, 0, 0},
+		{"negative free balance", 1000, -5, 0, half, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := Allocate(tt.total, tt.available, tt.lent, 0, tt.dist, 150)
+			if plan.Fix != tt.wantFix || plan.Predict != tt.wantPredict {
+				t.Errorf("plan = %+v, want Fix %v, Predict %v", plan, tt.wantFix, tt.wantPredict)
+			}
+			if sum := plan.Fix + plan.Predict; sum > tt.available && sum > 0 {
+				t.Errorf("planned %v, more than the %v available", sum, tt.available)
+			}
+		})
+	}
+}
+
+func TestAllocateCountsOfferedCapitalOnce(t *testing.T) {
+	half := Distribution{Fix: 0.5, Predict: 0.5}
+
+	// Offered capital is neither free nor lent: it is already out of
+	// available and only counts once against the buckets
+	tests := []struct {
+		name        string
+		total       float64
+		available   float64
+		lent        float64
+		offered     float64
+		dist        Distribution
+		wantFix     float64
+		wantPredict float64
+	}{
+		{"offers only", 1000, 600, 0, 400, half, 300, 300},
+		{"lent and offered", 1000, 300, 500, 200, half, 150, 150},
+		{"everything lent or offered", 1000, 0, 600, 400, half, 0, 0},
+		{"everything offered", 1000, 0, 0, 1000, half, 0, 0},
+		{"offered beyond the lending share", 1000, 100, 0, 900, Distribution{Fix: 0.4, Predict: 0.4, Reserve: 0.2}, 0, 0},
+		{"offered within the lending share", 2000, 1200, 0, 800, Distribution{Fix: 0.4, Predict: 0.4, Reserve: 0.2}, 400, 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := Allocate(tt.total, tt.available, tt.lent, tt.offered, tt.dist, 150)
+			if plan.Fix != tt.wantFix || plan.Predict != tt.wantPredict {
+				t.Errorf("plan = %+v, want Fix %v, Predict %v", plan, tt.wantFix, tt.wantPredict)
+			}
+		})
+	}
+}
+
+func TestAllocateDecimals(t *testing.T) {
+	half := Distribution{Fix: 0.5, Predict: 0.5}
+
+	tests := []struct {
+		name        string
+		decimals    int
+		wantFix     float64
+		wantPredict float64
+	}{
+		{"cents", 2, 0.5, 0.5},
+		{"satoshis", 8, 0.50617283, 0.50617283},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := AllocateDecimals(1.01234567, 1.01234567, 0, 0, half, 0.001, tt.decimals)
+			if plan.Fix != tt.wantFix || plan.Predict != tt.wantPredict {
+				t.Errorf("plan = %+v, want Fix %v, Predict %v", plan, tt.wantFix, tt.wantPredict)
+			}
+		})
+	}
+}
+
+func TestSplitsNeverExceedBalance(t *testing.T) {
+	// Balances that are not exact in float64 or do not divide evenly
+	tests := []struct {
+		name      string
+		total     float64
+		available float64
+		lent      float64
+		offered   float64
+		dist      Distribution
+		tiers     int
+	}{
+		{"thirds", 1000, 1000, 0, 0, Distribution{Fix: 1.0 / 3, Predict: 2.0 / 3}, 3},
+		{"float sums", 0.1 + 0.2 + 999.7, 0.1 + 0.2 + 999.7, 0, 0, Distribution{Fix: 0.5, Predict: 0.5}, 7},
+		{"odd cents", 1000.07, 1000.07, 0, 0, Distribution{Fix: 0.5, Predict: 0.5}, 3},
+		{"after lending", 5432.19, 1234.57, 3000.01, 1197.61, Distribution{Fix: 0.45, Predict: 0.45, Reserve: 0.1}, 6},
+		{"short balance", 10000, 3333.33, 0, 0, Distribution{Fix: 0.7, Predict: 0.3}, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := Allocate(tt.total, tt.available, tt.lent, tt.offered, tt.dist, 150)
+			if got, limit := util.ToUnits(plan.Fix, 2)+util.ToUnits(plan.Predict, 2), util.ToUnits(tt.available, 2); got > limit {
+				t.Fatalf("plan %+v sums to %d cents, more than the %d available", plan, got, limit)
+			}
+
+			// Each bucket is split again into ladder tiers
+			for _, bucket := range []float64{plan.Fix, plan.Predict} {
+				if bucket == 0 {
+					continue
+				}
+				var sum int64
+				for _, offer := range BuildLadder(bucket, tt.tiers, 0.0002, 0.0004, 2, 150, 2) {
+					amount, err := strconv.ParseFloat(offer.Amount, 64)
+					if err != nil {
+						t.Fatalf("offer amount %q: %v", offer.Amount, err)
+					}
+					sum += util.ToUnits(amount, 2)
+				}
+				if limit := util.ToUnits(bucket, 2); sum > limit {
+					t.Errorf("ladder of %v sums to %d cents, more than %d", bucket, sum, limit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+// BacktestResult summarizes a strategy replayed over recorded market states
+type BacktestResult struct {
+	Cycles          int     // Market states replayed
+	OffersPlaced    int     // Offers accepted by the simulated exchange
+	AmountOffered   float64 // Total amount offered
+	AmountFilled    float64 // Amount matched against the recorded books
+	FillRate        float64 // AmountFilled / AmountOffered
+	Interest        float64 // Interest earned after LendingFee, summed across currencies
+	AnnualizedYield float64 // Interest per unit of capital per year
+	IdleFraction    float64 // Share of capital-time neither offered nor lent
+}
+
+// Backtest replays history through the default strategy. See
+// BacktestStrategy for how history is interpreted.
+func Backtest(history []data.MarketState, cfg Config) (BacktestResult, error) {
+	// A simulation must never overwrite the live bot's tracked orders
+	cfg.PredictOrdersFile = ""
+	return BacktestStrategy(history, cfg, NewDefaultStrategy(cfg))
+}
+
+// BacktestStrategy replays history through s. Entries of the same symbol are
+// taken to be cfg.Interval apart. Only Symbol, Book and Stats are read from
+// each entry, plus TotalBalance from the first entry of each symbol as the
+// starting capital; balances, offers and credits are simulated. An offer
+// fills against recorded bids paying at least its rate, FRRDELTA offers at
+// the recorded FRR plus their delta, and credits accrue interest until their
+// period ends.
+func BacktestStrategy(history []data.MarketState, cfg Config, s Strategy) (BacktestResult, error) {
+	if cfg.Interval <= 0 {
+		return BacktestResult{}, fmt.Errorf("interval must be positive")
+	}
+
+	var (
+		res          BacktestResult
+		capitalYears float64 // Capital weighted by time, in years
+		idleYears    float64 // Idle capital weighted by time, in years
+		nextID       int
+	)
+	ctx := context.Background()
+	accounts := make(map[string]*simAccount)
+	step := cfg.Interval.Hours() / 24 / data.DaysPerYear
+
+	// Age tracked orders by simulated rather than wall-clock time
+	var clock time.Time
+	if ds, ok := s.(*DefaultStrategy); ok {
+		ds.now = func() time.Time { return clock }
+	}
+
+	for _, recorded := range history {
+		acct, ok := accounts[recorded.Symbol]
+		if !ok {
+			acct = &simAccount{now: time.Unix(0, 0).UTC(), total: recorded.TotalBalance}
+			accounts[recorded.Symbol] = acct
+		} else {
+			res.Interest += acct.advance(cfg.Interval, cfg.LendingFee)
+		}
+
+		clock = acct.now
+		state := acct.state(recorded)
+		offers, cancels, err := s.Decide(ctx, state)
+		if err != nil {
+			return res, fmt.Errorf("cycle %d (%s): strategy decision failed: %w", res.Cycles, recorded.Symbol, err)
+		}
+
+		acct.cancel(cancels)
+		for _, req := range offers {
+			nextID++
+			offer, ok := acct.place(req, nextID)
+			if !ok {
+				continue
+			}
+			res.OffersPlaced++
+			res.AmountOffered += offer.Amount
+			if observer, ok := s.(OfferObserver); ok {
+				observer.OfferPlaced(req, &offer)
+			}
+		}
+
+		frr := 0.0
+		if len(recorded.Stats) > 0 {
+			frr = recorded.Stats[0].FRR
+		}
+		res.AmountFilled += acct.fill(recorded.Book, frr, &nextID)
+
+		capitalYears += acct.total * step
+		idleYears += acct.available() * step
+		res.Cycles++
+	}
+
+	if res.AmountOffered > 0 {
+		res.FillRate = res.AmountFilled / res.AmountOffered
+	}
+	if capitalYears > 0 {
+		res.AnnualizedYield = res.Interest / capitalYears
+		res.IdleFraction = idleYears / capitalYears
+	}
+	return res, nil
+}
+
+// simAccount is the simulated funding wallet of one symbol
+type simAccount struct {
+	now     time.Time // Simulated time, starting at the Unix epoch
+	total   float64   // Capital including interest earned
+	offers  []data.FundingOffer
+	credits []data.FundingCredit
+}
+
+// state builds the snapshot the strategy sees from the recorded market and
+// the simulated account
+func (a *simAccount) state(recorded data.MarketState) data.MarketState {
+	return data.MarketState{
+		Symbol:           recorded.Symbol,
+		TotalBalance:     a.total,
+		AvailableBalance: a.available(),
+		ActiveOffers:     append([]data.FundingOffer{}, a.offers...),
+		Credits:          append([]data.FundingCredit{}, a.credits...),
+		Book:             recorded.Book,
+		Stats:            recorded.Stats,
+	}
+}
+
+// available returns the capital neither offered nor lent
+func (a *simAccount) available() float64 {
+	free := a.total
+	for _, offer := range a.offers {
+		free -= offer.Amount
+	}
+	for _, credit := range a.credits {
+		free -= credit.Amount
+	}
+	return free
+}
+
+// advance moves the clock by interval, accruing interest after fee on the
+// credits and releasing the ones whose period ended. It returns the
+// interest earned.
+func (a *simAccount) advance(interval time.Duration, fee float64) float64 {
+	end := a.now.Add(interval)
+	var interest float64
+
+	kept := a.credits[:0]
+	for _, credit := range a.credits {
+		due := credit.ExpiresAt()
+		until := end
+		if due.Before(until) {
+			until = due
+		}
+		if until.After(a.now) {
+			days := until.Sub(a.now).Hours() / 24
+			interest += credit.Amount * credit.Rate * days * (1 - fee)
+		}
+		if due.After(end) {
+			kept = append(kept, credit)
+		}
+	}
+	a.credits = kept
+	a.total += interest
+	a.now = end
+	return interest
+}
+
+// cancel removes the offers with the given IDs
+func (a *simAccount) cancel(ids []int) {
+	cancelled := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		cancelled[id] = true
+	}
+
+	kept := a.offers[:0]
+	for _, offer := range a.offers {
+		if !cancelled[offer.ID] {
+			kept = append(kept, offer)
+		}
+	}
+	a.offers = kept
+}
+
+// place opens an offer, rejecting it like the exchange would when it is
+// invalid or exceeds the available balance
+func (a *simAccount) place(req data.FundingOfferRequest, id int) (data.FundingOffer, bool) {
+	if err := req.Validate(); err != nil {
+		return data.FundingOffer{}, false
+	}
+	amount, _ := strconv.ParseFloat(req.Amount, 64)
+	rate, _ := strconv.ParseFloat(req.Rate, 64)
+	if amount > a.available() {
+		return data.FundingOffer{}, false
+	}
+
+	offerType := req.Type
+	if offerType == "" {
+		offerType = data.OfferTypeLimit
+	}
+	offer := data.FundingOffer{
+		ID:             id,
+		Symbol:         req.Symbol,
+		CreatedAt:      a.now,
+		UpdatedAt:      a.now,
+		Amount:         amount,
+		AmountOriginal: amount,
+		Type:           offerType,
+		Flags:          req.Flags,
+		Status:         "ACTIVE",
+		Rate:           rate,
+		Period:         req.Period,
+	}
+	a.offers = append(a.offers, offer)
+	return offer, true
+}
+
+// fill matches the open offers, cheapest first, against the bids in book
+// and turns the matched amounts into credits. It returns the amount filled.
+func (a *simAccount) fill(book []data.BitfinexOffer, frr float64, nextID *int) float64 {
+	// Best bids first, with their remaining depth; bids have negative amounts
+	var bids []data.BitfinexOffer
+	for _, entry := range book {
+		if entry.Amount < 0 {
+			entry.Amount = -entry.Amount
+			bids = append(bids, entry)
+		}
+	}
+	sort.Slice(bids, func(i, j int) bool { return bids[i].Rate > bids[j].Rate })
+
+	effectiveRate := func(offer data.FundingOffer) float64 {
+		if offer.Type == data.OfferTypeLimit {
+			return offer.Rate
+		}
+		return frr + offer.Rate
+	}
+	sort.SliceStable(a.offers, func(i, j int) bool {
+		return effectiveRate(a.offers[i]) < effectiveRate(a.offers[j])
+	})
+
+	var filled float64
+	kept := a.offers[:0]
+	for _, offer := range a.offers {
+		rate := effectiveRate(offer)
+		for i := range bids {
+			if offer.Amount <= 0 || bids[i].Rate < rate {
+				break
+			}
+			take := offer.Amount
+			if bids[i].Amount < take {
+				take = bids[i].Amount
+			}
+			if take <= 0 {
+				continue
+			}
+
+			*nextID++
+			a.credits = append(a.credits, data.FundingCredit{
+				ID:       int64(*nextID),
+				Symbol:   offer.Symbol,
+				Status:   "ACTIVE",
+				Amount:   take,
+				Rate:     rate,
+				Period:   offer.Period,
+				OpenedAt: a.now,
+			})
+			bids[i].Amount -= take
+			offer.Amount -= take
+			filled += take
+		}
+		if offer.Amount > 0 {
+			kept = append(kept, offer)
+		}
+	}
+	a.offers = kept
+	return filled
+}
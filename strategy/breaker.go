@@ -0,0 +1,77 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+// circuitBreaker halts trading after BreakerThreshold consecutive failed
+// cycles. While open, no cycle runs; once BreakerCooldown has passed the
+// API is pinged and trading resumes if it answers.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	failures  int       // Consecutive failed cycles
+	open      bool      // Trading halted
+	retryAt   time.Time // When to ping again while open
+	lastError error     // Error of the cycle that tripped the breaker
+}
+
+// newCircuitBreaker creates the breaker configured by cfg
+func newCircuitBreaker(cfg Config) *circuitBreaker {
+	return &circuitBreaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown}
+}
+
+// record counts the outcome of a cycle and trips the breaker on the
+// threshold-th consecutive failure
+func (b *circuitBreaker) record(cfg Config, err error) {
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return
+	}
+
+	b.open = true
+	b.lastError = err
+	b.retryAt = time.Now().Add(b.cooldown)
+	logger.Errorf("Circuit breaker tripped after %d failed cycles, halting trading for %s: %v",
+		b.failures, b.cooldown, err)
+	cfg.notify(Event{
+		Type:    EventBreakerTripped,
+		Message: fmt.Sprintf("Trading halted after %d failed cycles: %v", b.failures, err),
+	})
+}
+
+// allow reports whether a cycle may run. While the breaker is open it pings
+// the API once the cool-down has passed and closes again if that succeeds.
+func (b *circuitBreaker) allow(ctx context.Context, client *data.Client, cfg Config) bool {
+	if !b.open {
+		return true
+	}
+	if time.Now().Before(b.retryAt) {
+		return false
+	}
+
+	if err := client.PingContext(ctx); err != nil {
+		b.retryAt = time.Now().Add(b.cooldown)
+		logger.Warnf("Circuit breaker still open, API check failed: %v", err)
+		return false
+	}
+
+	b.open = false
+	b.failures = 0
+	logger.Infof("Circuit breaker reset, API check succeeded; resuming trading")
+	cfg.notify(Event{
+		Type:    EventBreakerReset,
+		Message: fmt.Sprintf("Trading resumed after the API recovered from: %v", b.lastError),
+	})
+	return true
+}
@@ -0,0 +1,570 @@
+package strategy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+	"github.com/joho/godotenv"
+)
+
+// redacted replaces secret values when a config is printed
+const redacted = "********"
+
+// PredictMode selects how predictive offers are priced
+type PredictMode string
+
+const (
+	// PredictModeFixed places LIMIT offers at FRR * PredictRateMultiplier
+	PredictModeFixed PredictMode = "fixed"
+
+	// PredictModeFRRDelta places FRRDELTAVAR offers that float at
+	// FRR + PredictFRRDelta while open
+	PredictModeFRRDelta PredictMode = "frr_delta"
+)
+
+// Config holds the fully resolved bot configuration
+type Config struct {
+	APIKey       string       `json:"api_key"`      // Bitfinex API key
+	APISecret    string       `json:"api_secret"`   // Bitfinex API secret
+	Distribution Distribution `json:"distribution"` // Fund allocation ratio
+
+	// Symbols are the funding symbols lent out, each planned independently
+	Symbols []string `json:"symbols"`
+
+	// MinOfferAmount is the smallest amount worth placing as an offer
+	MinOfferAmount float64 `json:"min_offer_amount"`
+
+	// MinAmounts replaces MinOfferAmount and MinTotalBalance per symbol, in
+	// native units, e.g. {"fBTC": 0.002}; currencies not worth about a USD
+	// per unit need an entry to be lent at all
+	MinAmounts map[string]float64 `json:"min_amounts"`
+
+	// ReserveAmount and ReservePercent keep cash unlent in each symbol's
+	// wallet, e.g. for margin calls or withdrawals: a fixed amount, or a
+	// share of the balance (e.g. 0.1 for 10%). When both are set the larger
+	// reserve applies (0 disables).
+	ReserveAmount  float64 `json:"reserve_amount"`
+	ReservePercent float64 `json:"reserve_percent"`
+
+	// PredictRateMultiplier scales FRR to price predictive offers
+	PredictRateMultiplier float64 `json:"predict_rate_multiplier"`
+
+	// PredictPeriod is the period in days of predictive offers
+	PredictPeriod int `json:"predict_period"`
+
+	// PredictMode selects fixed-rate or FRR-tracking predictive offers
+	PredictMode PredictMode `json:"predict_mode"`
+
+	// PredictFRRDelta is the daily rate added to FRR in frr_delta mode; it
+	// may be negative to undercut FRR
+	PredictFRRDelta float64 `json:"predict_frr_delta"`
+
+	// FRRSmoothingWindow prices predictive offers off the average FRR of
+	// this many of the newest funding statistics instead of the latest one
+	// alone (0 or 1 uses the latest). In frr_delta mode it only affects the
+	// benchmark and floor checks, as Bitfinex applies the live FRR.
+	FRRSmoothingWindow int `json:"frr_smoothing_window"`
+
+	// PredictRepriceBps keeps a tracked predictive offer instead of
+	// cancelling and replacing it while the newly computed rate is within
+	// this many basis points of its rate (0 reprices every cycle)
+	PredictRepriceBps float64 `json:"predict_reprice_bps"`
+
+	// MaxOfferAge cancels a tracked predictive offer that is still unfilled
+	// this long after it was placed, whatever its rate, so its capital is
+	// offered again at the current market (0 disables)
+	MaxOfferAge time.Duration `json:"max_offer_age"`
+
+	// UndercutBps prices the fixed offer this many basis points below the
+	// lowest lender ask for its period so it is matched first; the result
+	// never goes below MinAcceptableRate or the best borrower bid (0 prices
+	// at the best bid)
+	UndercutBps float64 `json:"undercut_bps"`
+
+	// OptimizePeriod lets the fixed leg lend for longer periods when the book
+	// pays a term premium instead of always using the shortest period
+	OptimizePeriod bool `json:"optimize_period"`
+
+	// MinTermPremium is how much more (e.g. 0.1 for 10%) a longer period's
+	// rate must pay over the shortest period's rate to be chosen when
+	// OptimizePeriod is set
+	MinTermPremium float64 `json:"min_term_premium"`
+
+	// LadderTiers splits the fixed allocation into this many offers
+	LadderTiers int `json:"ladder_tiers"`
+
+	// LadderMaxRateMultiplier sets the top ladder rate relative to the best
+	// book rate
+	LadderMaxRateMultiplier float64 `json:"ladder_max_rate_multiplier"`
+
+	// Interval is the time between strategy cycles
+	Interval time.Duration `json:"interval"`
+
+	// MinTotalBalance skips a currency's cycle when its total funding
+	// balance is below this amount
+	MinTotalBalance float64 `json:"min_total_balance"`
+
+	// OfferFlags are the funding flags set on every submitted offer
+	OfferFlags data.FundingFlags `json:"offer_flags"`
+
+	// UseHiddenOffers keeps submitted offers out of the public funding book
+	// so their size does not move it
+	UseHiddenOffers bool `json:"use_hidden_offers"`
+
+	// MaxOpenOffers caps the number of active offers per currency,
+	// counting existing ones (0 means unlimited)
+	MaxOpenOffers int `json:"max_open_offers"`
+
+	// BenchmarkAPR is a risk-free annual rate (e.g. 0.05 for 5% T-bills);
+	// offers whose net APR does not beat it are skipped (0 disables)
+	BenchmarkAPR float64 `json:"benchmark_apr"`
+
+	// MinAcceptableRate is the lowest daily rate worth lending at; offers
+	// priced below it are skipped and funds stay in the wallet (0 disables)
+	MinAcceptableRate float64 `json:"min_acceptable_rate"`
+
+	// LendingFee is the share of interest kept by the exchange
+	LendingFee float64 `json:"lending_fee"`
+
+	// InterOrderDelay staggers consecutive offer submissions, plus up to half
+	// as much random jitter, to smooth bursts of requests (0 disables)
+	InterOrderDelay time.Duration `json:"inter_order_delay"`
+
+	// ReplanDebounce delays a deposit-triggered replan so rapid wallet
+	// updates collapse into one cycle
+	ReplanDebounce time.Duration `json:"replan_debounce"`
+
+	// AllowedPeriods restricts offer periods to this set; computed periods
+	// are snapped to the nearest entry (empty allows any valid period)
+	AllowedPeriods []int `json:"allowed_periods"`
+
+	// CatchUpIdleThreshold enables catch-up pricing on the first cycle after
+	// startup when at least this much capital is idle (0 disables)
+	CatchUpIdleThreshold float64 `json:"catch_up_idle_threshold"`
+
+	// CatchUpAggressiveness moves catch-up rates from the normal rate toward
+	// the market-clearing rate (0 = normal pricing, 1 = clearing rate)
+	CatchUpAggressiveness float64 `json:"catch_up_aggressiveness"`
+
+	// BreakerThreshold halts trading after this many consecutive cycles in
+	// which every symbol failed (0 disables)
+	BreakerThreshold int `json:"breaker_threshold"`
+
+	// BreakerCooldown is how long trading stays halted before the API is
+	// checked again; trading resumes once the check succeeds
+	BreakerCooldown time.Duration `json:"breaker_cooldown"`
+
+	// CancelOnShutdown cancels the strategy's actively managed offers (the
+	// predictive ones) when the bot is stopped
+	CancelOnShutdown bool `json:"cancel_on_shutdown"`
+
+	// NotifyWebhookURL receives a JSON POST for every notification event
+	// when set and Notifier is nil
+	NotifyWebhookURL string `json:"notify_webhook_url"`
+
+	// Notifier receives offer, error and low balance events (nil disables
+	// notifications). Set it in code to use a custom integration.
+	Notifier Notifier `json:"-"`
+
+	// OnFill is called when an offer placed by the bot leaves the active
+	// list and matching credits are found; Amount and Rate hold the filled
+	// amount and realized daily rate. Fills are matched on rate, so
+	// FRR-based offers are not reported. Set it in code, e.g. to alert or
+	// keep accounts.
+	OnFill func(data.FundingOffer) `json:"-"`
+
+	// MetricsAddr is the address, e.g. ":9090", on which Prometheus metrics
+	// are served at /metrics (empty disables the endpoint)
+	MetricsAddr string `json:"metrics_addr"`
+
+	// DryRun logs offers and cancellations instead of sending them; the
+	// simulated offers are not written to PredictOrdersFile
+	DryRun bool `json:"dry_run"`
+
+	// PerformanceFile is where cumulative performance stats are persisted
+	PerformanceFile string `json:"performance_file"`
+
+	// PredictOrdersFile is where tracked predictive orders are persisted so
+	// they can be cancelled after a restart (empty disables persistence)
+	PredictOrdersFile string `json:"predict_orders_file"`
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden
+func DefaultConfig() Config {
+	return Config{
+		Distribution: Distribution{
+			Fix:     0.5, // 50% for fixed lending
+			Predict: 0.5, // 50% for predictive lending
+		},
+		Symbols:               []string{"fUSD", "fUST"},
+		MinOfferAmount:        150,
+		PredictRateMultiplier: 1.3,
+		PredictPeriod:         2,
+		PredictMode:           PredictModeFixed,
+
+		MinTermPremium: 0.1,
+
+		LadderTiers:             1,
+		LadderMaxRateMultiplier: 1.5,
+
+		Interval:        300 * time.Second,
+		MinTotalBalance: 150,
+		MaxOpenOffers:   10,
+		LendingFee:      0.15,
+		ReplanDebounce:  5 * time.Second,
+
+		CatchUpIdleThreshold:  1000,
+		CatchUpAggressiveness: 0.5,
+
+		BreakerThreshold: 5,
+		BreakerCooldown:  15 * time.Minute,
+
+		PerformanceFile:   "performance.json",
+		PredictOrdersFile: "predict_orders.json",
+	}
+}
+
+// LoadConfig loads the configuration from the JSON file at path, or from
+// environment variables when path is empty. A config file holds any subset
+// of the fields printed by "config" and is merged over the defaults;
+// durations are strings like "10m" or "1h30m". The API key, secret and
+// webhook URL are then taken from the environment when set there. Either
+// way a .env file, if present, is loaded into the environment first.
+func LoadConfig(path string) (Config, error) {
+	if path != "" {
+		return loadConfigFile(path)
+	}
+
+	cfg := DefaultConfig()
+
+	if err := loadDotEnv(); err != nil {
+		return cfg, err
+	}
+
+	cfg.APIKey = os.Getenv("BITFINEX_API_KEY")
+	cfg.APISecret = os.Getenv("BITFINEX_API_SECRET")
+	cfg.DryRun = os.Getenv("BITFINEX_DRY_RUN") == "true"
+	cfg.NotifyWebhookURL = os.Getenv("BITFINEX_NOTIFY_WEBHOOK_URL")
+	cfg.MetricsAddr = os.Getenv("BITFINEX_METRICS_ADDR")
+	if symbols := os.Getenv("BITFINEX_SYMBOLS"); symbols != "" {
+		cfg.Symbols = nil
+		for _, symbol := range strings.Split(symbols, ",") {
+			if symbol = strings.TrimSpace(symbol); symbol != "" {
+				cfg.Symbols = append(cfg.Symbols, symbol)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// loadConfigFile implements LoadConfig for a config file
+func loadConfigFile(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	// Reject unknown keys so a typo does not silently leave a default
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	file := newConfigJSON(cfg)
+	if err := dec.Decode(&file); err != nil {
+		return cfg, fmt.Errorf("error parsing config file: %w", err)
+	}
+	cfg = file.config()
+
+	if err := loadDotEnv(); err != nil {
+		return cfg, err
+	}
+	for env, field := range map[string]*string{
+		"BITFINEX_API_KEY":            &cfg.APIKey,
+		"BITFINEX_API_SECRET":         &cfg.APISecret,
+		"BITFINEX_NOTIFY_WEBHOOK_URL": &cfg.NotifyWebhookURL,
+	} {
+		if value := os.Getenv(env); value != "" {
+			*field = value
+		}
+	}
+
+	return cfg, nil
+}
+
+// offerFlags returns the flags to set on submitted offers
+func (c Config) offerFlags() int {
+	flags := c.OfferFlags
+	if c.UseHiddenOffers {
+		flags = flags.With(data.FundingFlagHidden)
+	}
+	return int(flags)
+}
+
+// canPlaceOffer reports whether another offer fits under MaxOpenOffers
+func (c Config) canPlaceOffer(openOffers int) bool {
+	return c.MaxOpenOffers <= 0 || openOffers < c.MaxOpenOffers
+}
+
+// netAPR converts a daily rate into an annual rate after the lending fee
+func (c Config) netAPR(dailyRate float64) float64 {
+	return data.DailyToAnnual(dailyRate) * (1 - c.LendingFee)
+}
+
+// beatsBenchmark reports whether an offer at dailyRate earns more than
+// BenchmarkAPR after fees, logging the comparison
+func (c Config) beatsBenchmark(dailyRate float64) bool {
+	if c.BenchmarkAPR <= 0 {
+		return true
+	}
+
+	net := c.netAPR(dailyRate)
+	beats := net > c.BenchmarkAPR
+	logger.Infof("Net APR %.2f%% vs benchmark %.2f%%: beats benchmark = %t", net*100, c.BenchmarkAPR*100, beats)
+	return beats
+}
+
+// meetsMinRate reports whether dailyRate is at or above MinAcceptableRate,
+// logging the skip when it is not
+func (c Config) meetsMinRate(dailyRate float64) bool {
+	if c.MinAcceptableRate <= 0 || dailyRate >= c.MinAcceptableRate {
+		return true
+	}
+
+	logger.Infof("Rate %.6f%% is below the minimum acceptable rate %.6f%%", dailyRate*100, c.MinAcceptableRate*100)
+	return false
+}
+
+// undercutRate lowers rate by UndercutBps, bounded by MinAcceptableRate
+func (c Config) undercutRate(rate float64) float64 {
+	undercut := rate * (1 - c.UndercutBps/10000)
+	if c.MinAcceptableRate > 0 && undercut < c.MinAcceptableRate {
+		return c.MinAcceptableRate
+	}
+	return undercut
+}
+
+// cashReserve returns the amount of a wallet holding total that must stay
+// unlent
+func (c Config) cashReserve(total float64) float64 {
+	return math.Max(c.ReserveAmount, total*c.ReservePercent)
+}
+
+// minOfferAmount returns the smallest offer worth placing on symbol
+func (c Config) minOfferAmount(symbol string) float64 {
+	if amount, ok := c.MinAmounts[symbol]; ok {
+		return amount
+	}
+	return c.MinOfferAmount
+}
+
+// minTotalBalance returns the balance below which symbol is not lent
+func (c Config) minTotalBalance(symbol string) float64 {
+	if amount, ok := c.MinAmounts[symbol]; ok {
+		return amount
+	}
+	return c.MinTotalBalance
+}
+
+// keepsPredictRate reports whether an offer at tracked is close enough to
+// the computed rate to be left in place
+func (c Config) keepsPredictRate(tracked, computed float64) bool {
+	if c.PredictRepriceBps <= 0 {
+		return false
+	}
+	return math.Abs(computed-tracked) <= math.Abs(tracked)*c.PredictRepriceBps/10000
+}
+
+// predictOfferType returns the offer type of predictive offers
+func (c Config) predictOfferType() string {
+	if c.PredictMode == PredictModeFRRDelta {
+		return data.OfferTypeFRRDeltaVar
+	}
+	return data.OfferTypeLimit
+}
+
+// predictOffersDistinct reports whether open predictive offers can be told
+// apart from fixed ones: they are FRRDELTA offers or the fixed leg is off.
+// The fixed leg may lend LIMIT offers for any period, including the
+// predictive one, since both are snapped to the same AllowedPeriods.
+func (c Config) predictOffersDistinct() bool {
+	return c.PredictMode == PredictModeFRRDelta || c.Distribution.Fix == 0
+}
+
+// snapPeriod rounds a computed period to the nearest allowed period that
+// the exchange accepts
+func (c Config) snapPeriod(p int) int {
+	best := p
+	for i, allowed := range c.AllowedPeriods {
+		if i == 0 || abs(allowed-p) < abs(best-p) {
+			best = allowed
+		}
+	}
+	return data.NearestValidPeriod(best)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// catchUpRate moves rate toward the market-clearing rate by
+// CatchUpAggressiveness
+func (c Config) catchUpRate(rate, clearingRate float64) float64 {
+	return rate - (rate-clearingRate)*c.CatchUpAggressiveness
+}
+
+// Validate checks that the configuration can be used to run the strategy
+func (c Config) Validate() error {
+	if c.APIKey == "" || c.APISecret == "" {
+		return fmt.Errorf("API key and secret must be set in environment variables")
+	}
+	if err := c.Distribution.Validate(); err != nil {
+		return fmt.Errorf("invalid distribution: %w", err)
+	}
+	if len(c.Symbols) == 0 {
+		return fmt.Errorf("at least one funding symbol must be configured")
+	}
+	for _, symbol := range c.Symbols {
+		if len(symbol) < 2 || !strings.HasPrefix(symbol, "f") {
+			return fmt.Errorf("invalid funding symbol %q, expected e.g. fUSD", symbol)
+		}
+	}
+	switch c.PredictMode {
+	case PredictModeFixed, PredictModeFRRDelta:
+	default:
+		return fmt.Errorf("unknown predict mode %q", c.PredictMode)
+	}
+	if c.ReserveAmount < 0 {
+		return fmt.Errorf("reserve amount must not be negative")
+	}
+	if c.ReservePercent < 0 || c.ReservePercent > 1 {
+		return fmt.Errorf("reserve percent must be between 0 and 1")
+	}
+	if c.NotifyWebhookURL != "" {
+		// The URL usually embeds a token, so it is left out of the error
+		u, err := url.Parse(c.NotifyWebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("notify webhook URL must be an http or https URL with a host")
+		}
+	}
+	return nil
+}
+
+// loadDotEnv loads a .env file from the working directory into the
+// environment when there is one
+func loadDotEnv() error {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error loading .env file: %w", err)
+	}
+	return nil
+}
+
+// configFields has the fields of Config without its JSON methods
+type configFields Config
+
+// configJSON is the JSON form of Config, with durations written as strings
+// like "10m" instead of nanoseconds
+type configJSON struct {
+	configFields
+	MaxOfferAge     duration `json:"max_offer_age"`
+	Interval        duration `json:"interval"`
+	InterOrderDelay duration `json:"inter_order_delay"`
+	ReplanDebounce  duration `json:"replan_debounce"`
+	BreakerCooldown duration `json:"breaker_cooldown"`
+}
+
+func newConfigJSON(c Config) configJSON {
+	return configJSON{
+		configFields:    configFields(c),
+		MaxOfferAge:     duration(c.MaxOfferAge),
+		Interval:        duration(c.Interval),
+		InterOrderDelay: duration(c.InterOrderDelay),
+		ReplanDebounce:  duration(c.ReplanDebounce),
+		BreakerCooldown: duration(c.BreakerCooldown),
+	}
+}
+
+// config returns the Config j describes
+func (j configJSON) config() Config {
+	c := Config(j.configFields)
+	c.MaxOfferAge = time.Duration(j.MaxOfferAge)
+	c.Interval = time.Duration(j.Interval)
+	c.InterOrderDelay = time.Duration(j.InterOrderDelay)
+	c.ReplanDebounce = time.Duration(j.ReplanDebounce)
+	c.BreakerCooldown = time.Duration(j.BreakerCooldown)
+	return c
+}
+
+// MarshalJSON writes durations as strings like "10m"
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newConfigJSON(c))
+}
+
+// UnmarshalJSON reads a config written by MarshalJSON over c
+func (c *Config) UnmarshalJSON(raw []byte) error {
+	j := newConfigJSON(*c)
+	if err := json.Unmarshal(raw, &j); err != nil {
+		return err
+	}
+	*c = j.config()
+	return nil
+}
+
+// duration is a time.Duration written in JSON as a string like "10m". A
+// number is still read as nanoseconds for older config files.
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(raw []byte) error {
+	var nanos int64
+	if err := json.Unmarshal(raw, &nanos); err == nil {
+		*d = duration(nanos)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return fmt.Errorf("duration must be a string like \"10m\": %s", raw)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// String renders the configuration as indented JSON with secrets redacted
+func (c Config) String() string {
+	if c.APIKey != "" {
+		c.APIKey = redacted
+	}
+	if c.APISecret != "" {
+		c.APISecret = redacted
+	}
+	// Webhook URLs usually embed an access token
+	if c.NotifyWebhookURL != "" {
+		c.NotifyWebhookURL = redacted
+	}
+
+	out, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("error rendering config: %v", err)
+	}
+	return string(out)
+}
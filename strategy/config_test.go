@@ -0,0 +1,182 @@
+package strategy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFileDurations(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		wantErr      bool
+		wantInterval time.Duration
+		wantMaxAge   time.Duration
+	}{
+		{"default kept", `{}`, false, 300 * time.Second, 0},
+		{"strings", `{"interval": "10m", "max_offer_age": "1h30m"}`, false, 10 * time.Minute, 90 * time.Minute},
+		{"nanoseconds", `{"interval": 60000000000}`, false, time.Minute, 0},
+		{"invalid string", `{"interval": "ten minutes"}`, true, 0, 0},
+		{"unknown key", `{"intervall": "10m"}`, true, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cfg.Interval != tt.wantInterval || cfg.MaxOfferAge != tt.wantMaxAge {
+				t.Errorf("Interval, MaxOfferAge = %v, %v; want %v, %v", cfg.Interval, cfg.MaxOfferAge, tt.wantInterval, tt.wantMaxAge)
+			}
+		})
+	}
+}
+
+func TestConfigJSONRoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.InterOrderDelay = 1500 * time.Millisecond
+
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["interval"] != "5m0s" || fields["inter_order_delay"] != "1.5s" {
+		t.Errorf("durations written as %v and %v, want 5m0s and 1.5s", fields["interval"], fields["inter_order_delay"])
+	}
+
+	var got Config
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got.Interval != cfg.Interval || got.InterOrderDelay != cfg.InterOrderDelay || got.BreakerCooldown != cfg.BreakerCooldown {
+		t.Errorf("round trip gave %v, %v, %v", got.Interval, got.InterOrderDelay, got.BreakerCooldown)
+	}
+}
+
+func TestLoadConfigWithoutDotEnv(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	t.Setenv("BITFINEX_API_KEY", "key")
+	t.Setenv("BITFINEX_API_SECRET", "secret")
+	t.Setenv("BITFINEX_SYMBOLS", "fUSD, fBTC")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.APIKey != "key" || cfg.APISecret != "secret" || len(cfg.Symbols) != 2 || cfg.Symbols[1] != "fBTC" {
+		t.Errorf("cfg = %s, want key, secret and two symbols from the environment", cfg)
+	}
+}
+
+func TestValidateNotifyWebhookURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"unset", "", false},
+		{"https", "https://hooks.example.com/services/token", false},
+		{"no scheme", "hooks.example.com/services/token", true},
+		{"other scheme", "ftp://hooks.example.com", true},
+		{"no host", "https://", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.APIKey, cfg.APISecret = "key", "secret"
+			cfg.NotifyWebhookURL = tt.url
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigStringRedactsSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		secret  string
+		webhook string
+	}{
+		{"all set", "my-api-key", "my-api-secret", "https://hooks.example.com/my-token"},
+		{"only key", "my-api-key", "", ""},
+		{"none set", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.APIKey, cfg.APISecret, cfg.NotifyWebhookURL = tt.key, tt.secret, tt.webhook
+
+			out := cfg.String()
+			for _, secret := range []string{tt.key, tt.secret, tt.webhook} {
+				if secret != "" && strings.Contains(out, secret) {
+					t.Errorf("String() leaks %q:\n%s", secret, out)
+				}
+			}
+
+			var fields map[string]interface{}
+			if err := json.Unmarshal([]byte(out), &fields); err != nil {
+				t.Fatalf("String() is not JSON: %v", err)
+			}
+			if tt.key != "" && fields["api_key"] != redacted {
+				t.Errorf("api_key = %v, want %q", fields["api_key"], redacted)
+			}
+			if tt.key == "" && fields["api_key"] != "" {
+				t.Errorf("unset api_key = %v, want empty", fields["api_key"])
+			}
+		})
+	}
+}
+
+func TestSnapPeriod(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []int
+		in      int
+		want    int
+	}{
+		{"no allowed periods", nil, 45, 45},
+		{"below the exchange minimum", nil, 1, 2},
+		{"above the exchange maximum", nil, 121, 120},
+		{"nearest allowed", []int{2, 7, 30}, 10, 7},
+		{"tie goes to the first", []int{2, 6}, 4, 2},
+		{"allowed period out of range", []int{150}, 100, 120},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{AllowedPeriods: tt.allowed}
+			if got := cfg.snapPeriod(tt.in); got != tt.want {
+				t.Errorf("snapPeriod(%d) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,396 @@
+package strategy
+
+import (
+	"context"
+	"math"
+	"os"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// DefaultStrategy splits capital between a fixed leg priced off the funding
+// book and a predictive leg priced at a multiple of the FRR. Predictive
+// offers are repriced once their rate drifts beyond PredictRepriceBps.
+type DefaultStrategy struct {
+	cfg                 Config
+	started             map[string]bool // Symbols whose first cycle after startup has run
+	currentPredictOrder []CurrentPredictOrder
+	pendingPredict      []data.FundingOfferRequest // Predictive offers requested this cycle
+	adoptOnStart        bool                       // No persisted orders to trust; adopt open ones on the first cycle
+	now                 func() time.Time           // Clock used to age tracked orders
+}
+
+// NewDefaultStrategy creates the built-in strategy for cfg, restoring the
+// predictive orders tracked before the last shutdown
+func NewDefaultStrategy(cfg Config) *DefaultStrategy {
+	s := &DefaultStrategy{
+		cfg:          cfg,
+		started:      make(map[string]bool),
+		adoptOnStart: true,
+		now:          time.Now,
+	}
+
+	if cfg.PredictOrdersFile != "" {
+		orders, err := loadPredictOrders(cfg.PredictOrdersFile)
+		if err != nil {
+			errorLog.Printf("Failed to load tracked predict orders: %v", err)
+		} else if _, statErr := os.Stat(cfg.PredictOrdersFile); statErr == nil {
+			s.adoptOnStart = false
+		}
+		s.currentPredictOrder = orders
+	}
+	return s
+}
+
+// saveOrders persists the tracked predictive orders. Dry runs track their
+// simulated offers in memory only, so they never overwrite the orders of a
+// live run.
+func (s *DefaultStrategy) saveOrders() {
+	if s.cfg.PredictOrdersFile == "" || s.cfg.DryRun {
+		return
+	}
+	if err := savePredictOrders(s.cfg.PredictOrdersFile, s.currentPredictOrder); err != nil {
+		errorLog.Printf("Failed to save tracked predict orders: %v", err)
+	}
+}
+
+// Decide implements Strategy
+func (s *DefaultStrategy) Decide(ctx context.Context, state data.MarketState) ([]data.FundingOfferRequest, []int, error) {
+	cfg := s.cfg
+	distribution := cfg.Distribution
+	s.pendingPredict = nil
+
+	// Forget tracked orders that were filled or cancelled elsewhere
+	if state.ActiveOffers != nil {
+		var changed bool
+		s.currentPredictOrder, changed = reconcilePredictOrders(s.currentPredictOrder, state.Symbol, state.ActiveOffers)
+		if changed {
+			s.saveOrders()
+		}
+
+		if s.adoptOnStart && !s.started[state.Symbol] {
+			s.adoptOrders(state)
+		}
+	}
+
+	var offers []data.FundingOfferRequest
+
+	// Cancel tracked orders that sat unfilled too long; their capital is
+	// offered again once the cancellation has released it
+	cancels := s.cancelStaleOrders(state.Symbol)
+
+	currency := state.Currency()
+	decimals := data.CurrencyPrecision(state.Symbol)
+	minAmount := cfg.minOfferAmount(state.Symbol)
+	lent := state.Lent()
+	offered := state.Offered()
+
+	// The cash reserve comes off both the balance that is split and the free
+	// balance, so it stays in the wallet even when free capital is short
+	reserve := cfg.cashReserve(state.TotalBalance)
+	plan := AllocateDecimals(state.TotalBalance-reserve, state.AvailableBalance-reserve, lent, offered, distribution, minAmount, decimals)
+
+	logger.Infof("Allocation strategy: Fixed lending %.1f%%, Predictive lending %.1f%%, Reserve %.1f%%",
+		distribution.Fix*100, distribution.Predict*100, distribution.Reserve*100)
+	logger.Infof("Already lent: %.2f %s, offered: %.2f %s", lent, currency, offered, currency)
+	if reserve > 0 {
+		logger.Infof("Cash reserve: %.2f %s", reserve, currency)
+	}
+	logger.Infof("Remaining fixed lending: %.2f %s", plan.Fix, currency)
+	logger.Infof("Remaining predictive lending: %.2f %s", plan.Predict, currency)
+
+	// Price the first cycle aggressively if a lot of capital sat idle during downtime
+	catchUp := !s.started[state.Symbol] && cfg.CatchUpIdleThreshold > 0 && state.AvailableBalance >= cfg.CatchUpIdleThreshold
+	s.started[state.Symbol] = true
+	if catchUp {
+		logger.Infof("Catch-up mode: %.2f %s idle after startup, pricing aggressively", state.AvailableBalance, currency)
+	}
+
+	openOffers := len(state.ActiveOffers) - len(cancels)
+
+	// 1. Handle fixed lending
+	if plan.Fix > 0 {
+		// Find best offer; a missing book only skips the fixed leg
+		var bestOffer *data.BitfinexOffer
+		if state.Book == nil {
+			logger.Infof("Funding book unavailable, skipping fixed lending")
+		} else if best, err := s.selectBestOffer(state.Book); err != nil {
+			logger.Warnf("Error finding highest lending rate, skipping fixed lending: %v", err)
+		} else {
+			bestOffer = best
+		}
+
+		if bestOffer != nil && !cfg.canPlaceOffer(openOffers) {
+			logger.Infof("Max open offers (%d) reached, skipping fixed lending", cfg.MaxOpenOffers)
+			bestOffer = nil
+		}
+
+		// The rate the fixed offers start at, after any undercut
+		var fixedRate float64
+		if bestOffer != nil {
+			fixedRate = s.fixedRate(state.Book, bestOffer)
+		}
+
+		if bestOffer != nil && !cfg.beatsBenchmark(fixedRate) {
+			logger.Infof("Best offer does not beat the benchmark, skipping fixed lending")
+			bestOffer = nil
+		}
+
+		if bestOffer != nil && !cfg.meetsMinRate(fixedRate) {
+			logger.Infof("Best offer is below the rate floor, skipping fixed lending")
+			bestOffer = nil
+		}
+
+		if bestOffer != nil {
+			logger.Infof("Best offer found:")
+			logger.Debugf("Offer ID: %d", bestOffer.OfferID)
+			logger.Debugf("Period: %d days", bestOffer.Period)
+			logger.Debugf("Rate: %.6f%%", bestOffer.Rate*100)
+			logger.Debugf("Amount: %.2f %s", bestOffer.Amount, currency)
+
+			// Fixed lending orders, laddered across rate tiers
+			tiers := cfg.LadderTiers
+			if cfg.MaxOpenOffers > 0 && tiers > cfg.MaxOpenOffers-openOffers {
+				tiers = cfg.MaxOpenOffers - openOffers
+			}
+			ladder := BuildLadder(plan.Fix, tiers, fixedRate,
+				fixedRate*cfg.LadderMaxRateMultiplier, cfg.snapPeriod(bestOffer.Period),
+				math.Max(minAmount, data.MinFundingAmount(state.Symbol)), decimals)
+
+			for _, offer := range ladder {
+				offer.Symbol = state.Symbol
+				offer.Flags = cfg.offerFlags()
+				offers = append(offers, offer)
+			}
+			openOffers += len(ladder)
+		}
+	} else {
+		logger.Infof("No fixed lending requirement")
+	}
+
+	// 2. Handle predictive lending
+	if len(state.Stats) == 0 {
+		// Missing statistics only skip the predictive leg; tracked orders
+		// are left alone since there is no rate to compare them with
+		logger.Infof("Funding statistics unavailable, skipping predictive lending")
+		return offers, cancels, nil
+	}
+
+	var latestStat = state.Stats[0]
+	logger.Infof("Latest funding statistics:")
+	logger.Debugf("Timestamp: %d", latestStat.Timestamp)
+	logger.Debugf("FRR (Flash Return Rate): %.6f%%", data.DailyToAnnual(latestStat.FRR)*100)
+	logger.Debugf("Average Period: %.2f days", latestStat.AveragePeriod)
+	logger.Debugf("Total Funding: %.2f %s", latestStat.FundingAmount, currency)
+	logger.Debugf("Used Funding: %.2f %s", latestStat.FundingAmountUsed, currency)
+	logger.Debugf("Below Threshold Funding: %.2f %s", latestStat.FundingBelowThreshold, currency)
+
+	frr := data.SmoothedFRR(state.Stats, cfg.FRRSmoothingWindow)
+	if cfg.FRRSmoothingWindow > 1 {
+		logger.Debugf("Smoothed FRR over %d points: %.6f%%", cfg.FRRSmoothingWindow, data.DailyToAnnual(frr)*100)
+	}
+
+	// Calculate predicted rate (FRR * PredictRateMultiplier, or
+	// FRR + PredictFRRDelta for floating offers)
+	offerType := cfg.predictOfferType()
+	predictRate := frr * cfg.PredictRateMultiplier
+	if catchUp {
+		predictRate = cfg.catchUpRate(predictRate, latestStat.FRR)
+	}
+
+	// FRRDELTA offers carry the delta itself in the rate field; Bitfinex
+	// adds the live FRR, so smoothing only estimates the rate for the
+	// benchmark and floor checks
+	rateField := predictRate
+	if offerType != data.OfferTypeLimit {
+		rateField = cfg.PredictFRRDelta
+		if catchUp {
+			rateField = cfg.catchUpRate(rateField, 0)
+		}
+		predictRate = frr + rateField
+	}
+
+	// Replace existing prediction orders whose rate has drifted. This runs
+	// even when no new capital is free, and the cancelled amount is offered
+	// again at the new rate.
+	var repriced float64
+	kept := s.currentPredictOrder[:0]
+	for _, order := range s.currentPredictOrder {
+		if order.Symbol != state.Symbol {
+			kept = append(kept, order)
+			continue
+		}
+		if cfg.keepsPredictRate(order.Rate, rateField) {
+			logger.Infof("Keeping predictive order %d: rate %.6f%% is within %.1f bps of %.6f%%",
+				order.ID, order.Rate*100, cfg.PredictRepriceBps, rateField*100)
+			kept = append(kept, order)
+			continue
+		}
+		logger.Infof("Repricing predictive order %d: rate %.6f%% drifted from %.6f%%",
+			order.ID, order.Rate*100, rateField*100)
+		cancels = append(cancels, order.ID)
+		repriced += order.Amount
+		openOffers--
+	}
+	if len(kept) != len(s.currentPredictOrder) {
+		s.currentPredictOrder = kept
+		s.saveOrders()
+	}
+
+	if plan.Predict+repriced <= 0 {
+		logger.Infof("No predictive lending requirement")
+		return offers, cancels, nil
+	}
+
+	// Rounding down may push a borderline amount under the minimum
+	predictAmount := util.RoundDownAmount(plan.Predict+repriced, decimals)
+
+	if predictAmount < minAmount {
+		logger.Infof("Predictive amount %.2f %s rounds below the minimum %.2f, skipping predictive lending",
+			plan.Predict+repriced, currency, minAmount)
+	} else if !cfg.canPlaceOffer(openOffers) {
+		logger.Infof("Max open offers (%d) reached, skipping predictive lending", cfg.MaxOpenOffers)
+	} else if !cfg.beatsBenchmark(predictRate) {
+		logger.Infof("Predicted rate does not beat the benchmark, skipping predictive lending")
+	} else if !cfg.meetsMinRate(predictRate) {
+		logger.Infof("Predicted rate is below the rate floor, skipping predictive lending")
+	} else {
+		offer := data.FundingOfferRequest{
+			Type:   offerType,
+			Symbol: state.Symbol,
+			Amount: util.FormatAmount(predictAmount, decimals),
+			Rate:   util.FormatRate(rateField),
+			Period: cfg.snapPeriod(cfg.PredictPeriod),
+			Flags:  cfg.offerFlags(),
+		}
+
+		logger.Infof("Predictive lending order (%s): %.2f %s @ %.6f%% for %d days",
+			offerType, predictAmount, currency, predictRate*100, offer.Period)
+
+		offers = append(offers, offer)
+		s.pendingPredict = append(s.pendingPredict, offer)
+	}
+
+	return offers, cancels, nil
+}
+
+// adoptOrders takes over the predictive offers a previous run left open
+// when no orders file could be restored, so a restart does not place them
+// again. Offers are matched on type and period, and only when no fixed
+// offer can match too; otherwise every open offer is only logged, since
+// repricing a fixed offer as a predictive one would move it off the book.
+func (s *DefaultStrategy) adoptOrders(state data.MarketState) {
+	if !s.cfg.predictOffersDistinct() {
+		for _, offer := range state.ActiveOffers {
+			if offer.Symbol == state.Symbol {
+				logger.Infof("Leaving open %s offer %d (%s, %d days) unmanaged: predictive and fixed offers cannot be told apart",
+					state.Symbol, offer.ID, offer.Type, offer.Period)
+			}
+		}
+		return
+	}
+
+	adopted, other := adoptPredictOrders(state.Symbol, state.ActiveOffers,
+		s.cfg.predictOfferType(), s.cfg.snapPeriod(s.cfg.PredictPeriod))
+	for _, order := range adopted {
+		logger.Infof("Adopting open %s offer %d as a predictive order", state.Symbol, order.ID)
+	}
+	for _, offer := range other {
+		logger.Infof("Leaving open %s offer %d (%s, %d days) unmanaged", state.Symbol, offer.ID, offer.Type, offer.Period)
+	}
+
+	if len(adopted) > 0 {
+		s.currentPredictOrder = append(s.currentPredictOrder, adopted...)
+		s.saveOrders()
+	}
+}
+
+// cancelStaleOrders stops tracking the orders of symbol placed more than
+// MaxOfferAge ago and returns their IDs for cancellation
+func (s *DefaultStrategy) cancelStaleOrders(symbol string) []int {
+	if s.cfg.MaxOfferAge <= 0 {
+		return nil
+	}
+
+	now := s.now()
+	var stale []int
+	kept := s.currentPredictOrder[:0]
+	for _, order := range s.currentPredictOrder {
+		age := now.Sub(order.Since)
+		if order.Symbol == symbol && !order.Since.IsZero() && age > s.cfg.MaxOfferAge {
+			logger.Infof("Cancelling predictive order %d: unfilled for %s", order.ID, age.Round(time.Minute))
+			stale = append(stale, order.ID)
+			continue
+		}
+		kept = append(kept, order)
+	}
+
+	if len(stale) > 0 {
+		s.currentPredictOrder = kept
+		s.saveOrders()
+	}
+	return stale
+}
+
+// selectBestOffer picks the book entry the fixed leg is priced off: the best
+// shortest-period bid, or the best-paying period when OptimizePeriod is set
+func (s *DefaultStrategy) selectBestOffer(book []data.BitfinexOffer) (*data.BitfinexOffer, error) {
+	if !s.cfg.OptimizePeriod {
+		return data.SelectHighestRateForShortestPeriod(book)
+	}
+	return data.BestPeriodByYield(book, s.cfg.MinTermPremium)
+}
+
+// fixedRate returns the rate the fixed leg offers at. Without an undercut
+// this is the best bid's rate. With UndercutBps set the offer is priced just
+// below the lowest ask for the bid's period so it is first in the queue, but
+// never below the bid itself, which would already fill it on arrival.
+func (s *DefaultStrategy) fixedRate(book []data.BitfinexOffer, bestBid *data.BitfinexOffer) float64 {
+	if s.cfg.UndercutBps <= 0 {
+		return bestBid.Rate
+	}
+
+	ask, err := data.SelectLowestAsk(book, bestBid.Period)
+	if err != nil {
+		logger.Debugf("Not undercutting: %v", err)
+		return bestBid.Rate
+	}
+
+	rate := math.Max(s.cfg.undercutRate(ask.Rate), bestBid.Rate)
+	logger.Infof("Undercutting best ask %.6f%% to %.6f%%", ask.Rate*100, rate*100)
+	return rate
+}
+
+// OfferPlaced implements OfferObserver so predictive offers can be repriced
+// on the next cycle
+func (s *DefaultStrategy) OfferPlaced(req data.FundingOfferRequest, offer *data.FundingOffer) {
+	for i, pending := range s.pendingPredict {
+		if pending != req {
+			continue
+		}
+		s.currentPredictOrder = append(s.currentPredictOrder, CurrentPredictOrder{
+			Symbol:         req.Symbol,
+			ID:             offer.ID,
+			Rate:           offer.Rate,
+			Period:         offer.Period,
+			Since:          offer.CreatedAt,
+			Amount:         offer.Amount,
+			AmountOriginal: offer.AmountOriginal,
+		})
+		s.pendingPredict = append(s.pendingPredict[:i], s.pendingPredict[i+1:]...)
+		s.saveOrders()
+		return
+	}
+}
+
+// ManagedOffers implements OfferManager; the predictive offers are managed,
+// the fixed ones are left to run
+func (s *DefaultStrategy) ManagedOffers() []int {
+	ids := make([]int, 0, len(s.currentPredictOrder))
+	for _, order := range s.currentPredictOrder {
+		ids = append(ids, order.ID)
+	}
+	return ids
+}
@@ -0,0 +1,379 @@
+package strategy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+// testConfig returns a config that lends only through the predictive leg
+// and keeps no state on disk
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Distribution = Distribution{Predict: 1}
+	cfg.Symbols = []string{"fUSD"}
+	cfg.CatchUpIdleThreshold = 0
+	cfg.PredictOrdersFile = ""
+	cfg.PerformanceFile = ""
+	return cfg
+}
+
+// newTestStrategy creates a strategy tracking orders that does not adopt
+// open offers on its first cycle
+func newTestStrategy(cfg Config, orders ...CurrentPredictOrder) *DefaultStrategy {
+	s := NewDefaultStrategy(cfg)
+	s.adoptOnStart = false
+	s.currentPredictOrder = orders
+	return s
+}
+
+func TestDecideRepricesDriftedPredictOrders(t *testing.T) {
+	tracked := CurrentPredictOrder{Symbol: "fUSD", ID: 1, Rate: 0.0002, Period: 2, Amount: 500, AmountOriginal: 500}
+	active := []data.FundingOffer{{ID: 1, Symbol: "fUSD", Amount: 500, AmountOriginal: 500, Type: data.OfferTypeLimit, Rate: 0.0002, Period: 2}}
+
+	tests := []struct {
+		name        string
+		frr         float64
+		available   float64
+		wantCancels []int
+		wantAmount  string // Empty when no offer is expected
+	}{
+		{"within band is kept", 0.0002 / 1.3, 0, nil, ""},
+		{"drift with no free capital", 0.0003, 0, []int{1}, "500.00"},
+		{"drift adds to free capital", 0.0003, 200, []int{1}, "700.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.PredictRepriceBps = 10
+			s := newTestStrategy(cfg, tracked)
+
+			state := data.MarketState{
+				Symbol:           "fUSD",
+				TotalBalance:     500 + tt.available,
+				AvailableBalance: tt.available,
+				ActiveOffers:     active,
+				Stats:            []data.FundingStat{{FRR: tt.frr}},
+			}
+			offers, cancels, err := s.Decide(context.Background(), state)
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+
+			if len(cancels) != len(tt.wantCancels) || (len(cancels) > 0 && cancels[0] != tt.wantCancels[0]) {
+				t.Errorf("cancels = %v, want %v", cancels, tt.wantCancels)
+			}
+			if tt.wantAmount == "" {
+				if len(offers) != 0 {
+					t.Errorf("offers = %+v, want none", offers)
+				}
+				return
+			}
+			if len(offers) != 1 || offers[0].Amount != tt.wantAmount {
+				t.Fatalf("offers = %+v, want one offer of %s", offers, tt.wantAmount)
+			}
+		})
+	}
+}
+
+func TestDecideFRRDeltaRateField(t *testing.T) {
+	// Newest first; the smoothed FRR differs from the latest one
+	stats := []data.FundingStat{{FRR: 0.0003}, {FRR: 0.0002}, {FRR: 0.0001}}
+
+	tests := []struct {
+		name        string
+		tracked     []CurrentPredictOrder
+		wantCancels int
+		wantRate    string // Rate field of the placed offer; empty for none
+	}{
+		{"new offer carries the configured delta", nil, 0, "0.00001"},
+		{"offer at the delta is kept as FRR moves", []CurrentPredictOrder{
+			{Symbol: "fUSD", ID: 1, Rate: 0.00001, Period: 2, Amount: 500},
+		}, 0, ""},
+		{"offer at another delta is repriced", []CurrentPredictOrder{
+			{Symbol: "fUSD", ID: 1, Rate: 0.00005, Period: 2, Amount: 500},
+		}, 1, "0.00001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.PredictMode = PredictModeFRRDelta
+			cfg.PredictFRRDelta = 0.00001
+			cfg.FRRSmoothingWindow = 3
+			cfg.PredictRepriceBps = 10
+			s := newTestStrategy(cfg, tt.tracked...)
+
+			state := data.MarketState{Symbol: "fUSD", TotalBalance: 500, AvailableBalance: 500, Stats: stats}
+			if len(tt.tracked) > 0 {
+				state.AvailableBalance = 0
+				state.ActiveOffers = []data.FundingOffer{{ID: 1, Symbol: "fUSD", Amount: 500, Type: data.OfferTypeFRRDeltaVar, Rate: tt.tracked[0].Rate, Period: 2}}
+			}
+			offers, cancels, err := s.Decide(context.Background(), state)
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+
+			if len(cancels) != tt.wantCancels {
+				t.Errorf("cancels = %v, want %d", cancels, tt.wantCancels)
+			}
+			switch {
+			case tt.wantRate == "" && len(offers) != 0:
+				t.Errorf("offers = %+v, want none", offers)
+			case tt.wantRate != "" && (len(offers) != 1 || offers[0].Rate != tt.wantRate):
+				t.Errorf("offers = %+v, want one at rate %s", offers, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestOfferPlacedPersistsOnlyLiveOrders(t *testing.T) {
+	tests := []struct {
+		name     string
+		dryRun   bool
+		wantFile bool
+	}{
+		{"live", false, true},
+		{"dry run", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.DryRun = tt.dryRun
+			cfg.PredictOrdersFile = filepath.Join(t.TempDir(), "predict_orders.json")
+			s := newTestStrategy(cfg)
+
+			state := data.MarketState{Symbol: "fUSD", TotalBalance: 500, AvailableBalance: 500, Stats: []data.FundingStat{{FRR: 0.0002}}}
+			offers, _, err := s.Decide(context.Background(), state)
+			if err != nil || len(offers) != 1 {
+				t.Fatalf("Decide = %+v, %v; want one offer", offers, err)
+			}
+			s.OfferPlaced(offers[0], &data.FundingOffer{ID: -1, Symbol: "fUSD", Amount: 500, AmountOriginal: 500, Rate: 0.00026, Period: 2})
+
+			if len(s.ManagedOffers()) != 1 {
+				t.Errorf("managed offers = %v, want the placed offer", s.ManagedOffers())
+			}
+			_, statErr := os.Stat(cfg.PredictOrdersFile)
+			if gotFile := statErr == nil; gotFile != tt.wantFile {
+				t.Errorf("orders file written = %v, want %v", gotFile, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestDecideDegradesPerLeg(t *testing.T) {
+	book := []data.BitfinexOffer{{OfferID: 1, Period: 2, Rate: 0.0003, Amount: -5000}}
+	stats := []data.FundingStat{{FRR: 0.0002}}
+
+	tests := []struct {
+		name        string
+		book        []data.BitfinexOffer
+		stats       []data.FundingStat
+		wantFixed   int
+		wantPredict int
+	}{
+		{"both available", book, stats, 1, 1},
+		{"book failed", nil, stats, 0, 1},
+		{"stats failed", book, nil, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Distribution = Distribution{Fix: 0.5, Predict: 0.5}
+			s := newTestStrategy(cfg)
+
+			state := data.MarketState{Symbol: "fUSD", TotalBalance: 1000, AvailableBalance: 1000, Book: tt.book, Stats: tt.stats}
+			offers, _, err := s.Decide(context.Background(), state)
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+
+			// The fixed leg lends at the book rate, the predictive leg at 1.3x FRR
+			var fixed, predict int
+			for _, offer := range offers {
+				if offer.Rate == "0.0003" {
+					fixed++
+				} else {
+					predict++
+				}
+			}
+			if fixed != tt.wantFixed || predict != tt.wantPredict {
+				t.Errorf("offers = %+v, want %d fixed and %d predictive", offers, tt.wantFixed, tt.wantPredict)
+			}
+		})
+	}
+}
+
+func TestDecideRespectsMaxOpenOffers(t *testing.T) {
+	book := []data.BitfinexOffer{{OfferID: 1, Period: 2, Rate: 0.0003, Amount: -5000}}
+	open := func(n int) []data.FundingOffer {
+		var offers []data.FundingOffer
+		for i := 0; i < n; i++ {
+			offers = append(offers, data.FundingOffer{ID: 100 + i, Symbol: "fUSD", Amount: 10, Type: data.OfferTypeLimit, Rate: 0.0004, Period: 30})
+		}
+		return offers
+	}
+
+	tests := []struct {
+		name       string
+		maxOffers  int
+		open       int
+		wantOffers int
+	}{
+		{"unlimited", 0, 2, 4},
+		{"room for all", 10, 2, 4},
+		{"one slot left", 3, 2, 1},
+		{"limit reached", 2, 2, 0},
+		{"over the limit", 2, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Distribution = Distribution{Fix: 0.5, Predict: 0.5}
+			cfg.LadderTiers = 3
+			cfg.MaxOpenOffers = tt.maxOffers
+			s := newTestStrategy(cfg)
+
+			state := data.MarketState{
+				Symbol: "fUSD", TotalBalance: 2000, AvailableBalance: 2000 - 10*float64(tt.open),
+				ActiveOffers: open(tt.open), Book: book, Stats: []data.FundingStat{{FRR: 0.0002}},
+			}
+			offers, _, err := s.Decide(context.Background(), state)
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+			if len(offers) != tt.wantOffers {
+				t.Errorf("planned %d offers, want %d: %+v", len(offers), tt.wantOffers, offers)
+			}
+		})
+	}
+}
+
+func TestDecideSkipsRatesBelowBenchmark(t *testing.T) {
+	// Net of the 15% fee the book pays about 9.3% APR and the predictive
+	// rate of 1.3x FRR about 8.1%
+	book := []data.BitfinexOffer{{OfferID: 1, Period: 2, Rate: 0.0003, Amount: -5000}}
+
+	tests := []struct {
+		name       string
+		benchmark  float64
+		wantOffers int
+	}{
+		{"disabled", 0, 2},
+		{"both beat it", 0.05, 2},
+		{"only the book beats it", 0.085, 1},
+		{"neither beats it", 0.10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Distribution = Distribution{Fix: 0.5, Predict: 0.5}
+			cfg.BenchmarkAPR = tt.benchmark
+			s := newTestStrategy(cfg)
+
+			state := data.MarketState{Symbol: "fUSD", TotalBalance: 1000, AvailableBalance: 1000, Book: book, Stats: []data.FundingStat{{FRR: 0.0002}}}
+			offers, _, err := s.Decide(context.Background(), state)
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+			if len(offers) != tt.wantOffers {
+				t.Errorf("planned %d offers, want %d: %+v", len(offers), tt.wantOffers, offers)
+			}
+		})
+	}
+}
+
+func TestDecideCatchUpPricing(t *testing.T) {
+	// The normal predictive rate is 1.3x FRR; catch-up moves it halfway
+	// back to the FRR
+	tests := []struct {
+		name      string
+		available float64
+		cycles    int
+		wantRate  string
+	}{
+		{"small idle balance", 500, 1, "0.00026"},
+		{"large idle balance", 1000, 1, "0.00023"},
+		{"only the first cycle", 1000, 2, "0.00026"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.CatchUpIdleThreshold = 1000
+			cfg.CatchUpAggressiveness = 0.5
+			s := newTestStrategy(cfg)
+
+			state := data.MarketState{Symbol: "fUSD", TotalBalance: tt.available, AvailableBalance: tt.available, Stats: []data.FundingStat{{FRR: 0.0002}}}
+			var offers []data.FundingOfferRequest
+			for i := 0; i < tt.cycles; i++ {
+				var err error
+				if offers, _, err = s.Decide(context.Background(), state); err != nil {
+					t.Fatalf("Decide: %v", err)
+				}
+			}
+			if len(offers) != 1 || offers[0].Rate != tt.wantRate {
+				t.Errorf("offers = %+v, want one at rate %s", offers, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestDecideUndercutsBestAsk(t *testing.T) {
+	// Borrowers bid 0.0002 and lenders ask from 0.0003 for 2 days; the
+	// 30-day ask is for another period and ignored
+	book := []data.BitfinexOffer{
+		{OfferID: 1, Period: 2, Rate: 0.0002, Amount: -5000},
+		{OfferID: 2, Period: 2, Rate: 0.00035, Amount: 5000},
+		{OfferID: 3, Period: 2, Rate: 0.0003, Amount: 5000},
+		{OfferID: 4, Period: 30, Rate: 0.00025, Amount: 5000},
+	}
+
+	tests := []struct {
+		name      string
+		book      []data.BitfinexOffer
+		undercut  float64
+		minRate   float64
+		benchmark float64
+		wantRate  string // Empty when no offer is expected
+	}{
+		{"no undercut prices at the bid", book, 0, 0, 0, "0.0002"},
+		{"undercut prices below the lowest ask", book, 10, 0, 0, "0.0002997"},
+		{"undercut is bounded by the rate floor", book, 10, 0.00031, 0, "0.00031"},
+		{"undercut never goes below the bid", book, 5000, 0, 0, "0.0002"},
+		{"no asks prices at the bid", book[:1], 10, 0, 0, "0.0002"},
+		{"benchmark checks the undercut rate", book, 10, 0, 0.08, "0.0002997"},
+		{"benchmark without undercut", book, 0, 0, 0.08, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Distribution = Distribution{Fix: 1}
+			cfg.UndercutBps = tt.undercut
+			cfg.MinAcceptableRate = tt.minRate
+			cfg.BenchmarkAPR = tt.benchmark
+			s := newTestStrategy(cfg)
+
+			state := data.MarketState{Symbol: "fUSD", TotalBalance: 1000, AvailableBalance: 1000, Book: tt.book}
+			offers, _, err := s.Decide(context.Background(), state)
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+			switch {
+			case tt.wantRate == "" && len(offers) != 0:
+				t.Errorf("offers = %+v, want none", offers)
+			case tt.wantRate != "" && (len(offers) != 1 || offers[0].Rate != tt.wantRate || offers[0].Period != 2):
+				t.Errorf("offers = %+v, want one 2-day offer at rate %s", offers, tt.wantRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package strategy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// fakeExchange is a Doer serving canned Bitfinex responses for one funding
+// currency. Offers submitted through it are kept until fill or cancel
+// removes them.
+type fakeExchange struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	symbol    string
+	balance   float64
+	available float64
+	frr       float64
+	offers    []data.FundingOffer
+	credits   []data.FundingCredit
+	payments  []data.LedgerEntry
+	nextID    int
+	minimum   float64  // Smallest offer accepted (0 for none)
+	requests  []string // Paths requested, in order
+	submitted []data.FundingOfferRequest
+	rejected  []data.FundingOfferRequest
+	cancelled []int
+}
+
+func newFakeExchange(symbol string, balance, frr float64, now func() time.Time) *fakeExchange {
+	return &fakeExchange{now: now, symbol: symbol, balance: balance, available: balance, frr: frr, nextID: 100}
+}
+
+// newClient returns a client whose requests are served by e
+func (e *fakeExchange) newClient() *data.Client {
+	return data.NewClient("key", "secret", data.WithDoer(e), data.WithRateLimit(0, 0), data.WithLogger(util.NopLogger{}))
+}
+
+// fill turns the open offer id into a credit opened now
+func (e *fakeExchange) fill(id int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, offer := range e.offers {
+		if offer.ID != id {
+			continue
+		}
+		e.offers = append(e.offers[:i], e.offers[i+1:]...)
+		e.credits = append(e.credits, data.FundingCredit{
+			ID: int64(offer.ID) * 10, Symbol: offer.Symbol, Status: "ACTIVE",
+			Amount: offer.Amount, Rate: offer.Rate, Period: offer.Period, OpenedAt: e.now(),
+		})
+		return
+	}
+}
+
+// repay closes every credit, returning its principal plus interest to the
+// wallet
+func (e *fakeExchange) repay(interest float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, credit := range e.credits {
+		e.available += credit.Amount + interest
+		e.balance += interest
+		e.payments = append(e.payments, data.LedgerEntry{
+			ID: credit.ID, Currency: strings.TrimPrefix(e.symbol, "f"), CreatedAt: e.now(), Amount: interest, Balance: e.balance,
+		})
+	}
+	e.credits = nil
+}
+
+func (e *fakeExchange) Do(req *http.Request) (*http.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests = append(e.requests, req.URL.Path)
+
+	var body interface{}
+	switch path := req.URL.Path; {
+	case path == "/v2/platform/status":
+		body = []int{1}
+	case path == "/v2/auth/r/wallets":
+		body = [][]interface{}{{"funding", strings.TrimPrefix(e.symbol, "f"), e.balance, 0, e.available}}
+	case strings.HasPrefix(path, "/v2/auth/r/funding/offers"):
+		rows := [][]interface{}{}
+		for _, offer := range e.offers {
+			rows = append(rows, offerRow(offer))
+		}
+		body = rows
+	case strings.HasPrefix(path, "/v2/auth/r/funding/credits"):
+		rows := [][]interface{}{}
+		for _, credit := range e.credits {
+			opened := credit.OpenedAt.UnixMilli()
+			rows = append(rows, []interface{}{credit.ID, credit.Symbol, 1, opened, opened, credit.Amount, 0, credit.Status,
+				"FIXED", nil, nil, credit.Rate, credit.Period, opened})
+		}
+		body = rows
+	case strings.HasPrefix(path, "/v2/auth/r/ledgers/"):
+		rows := [][]interface{}{}
+		for i := len(e.payments) - 1; i >= 0; i-- {
+			payment := e.payments[i]
+			rows = append(rows, []interface{}{payment.ID, payment.Currency, nil, payment.CreatedAt.UnixMilli(), nil,
+				payment.Amount, payment.Balance, nil, "Margin Funding Payment on wallet funding"})
+		}
+		body = rows
+	case strings.HasPrefix(path, "/v2/book/"):
+		body = [][]interface{}{}
+	case strings.HasPrefix(path, "/v2/funding/stats/"):
+		body = [][]interface{}{{e.now().UnixMilli(), nil, nil, e.frr, 2.0, nil, nil, 1e6, 5e5, nil, nil, 0.0}}
+	case path == "/v2/auth/w/funding/offer/submit":
+		var offerReq data.FundingOfferRequest
+		if err := json.NewDecoder(req.Body).Decode(&offerReq); err != nil {
+			return nil, err
+		}
+		amount, _ := strconv.ParseFloat(offerReq.Amount, 64)
+		rate, _ := strconv.ParseFloat(offerReq.Rate, 64)
+		if amount < e.minimum {
+			e.rejected = append(e.rejected, offerReq)
+			body = []interface{}{e.now().UnixMilli(), "fon-req", nil, nil, nil, nil, "ERROR",
+				fmt.Sprintf("Invalid offer: incorrect amount, minimum is %v dollar or equivalent in USD", e.minimum)}
+			break
+		}
+		e.nextID++
+		offer := data.FundingOffer{
+			ID: e.nextID, Symbol: offerReq.Symbol, CreatedAt: e.now(), UpdatedAt: e.now(),
+			Amount: amount, AmountOriginal: amount, Type: offerReq.Type, Flags: offerReq.Flags,
+			Status: "ACTIVE", Rate: rate, Period: offerReq.Period,
+		}
+		e.offers = append(e.offers, offer)
+		e.available -= amount
+		e.submitted = append(e.submitted, offerReq)
+		body = []interface{}{e.now().UnixMilli(), "fon-req", nil, nil, offerRow(offer), nil, "SUCCESS", "Submitting funding offer"}
+	case path == "/v2/auth/w/funding/offer/cancel":
+		var cancelReq struct {
+			ID int `json:"id"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&cancelReq); err != nil {
+			return nil, err
+		}
+		for i, offer := range e.offers {
+			if offer.ID == cancelReq.ID {
+				e.offers = append(e.offers[:i], e.offers[i+1:]...)
+				e.available += offer.Amount
+				body = []interface{}{e.now().UnixMilli(), "foc-req", nil, nil, offerRow(offer), nil, "SUCCESS", "Cancelled"}
+				break
+			}
+		}
+		if body == nil {
+			body = []interface{}{e.now().UnixMilli(), "foc-req", nil, nil, nil, nil, "ERROR", "offer not found"}
+		}
+		e.cancelled = append(e.cancelled, cancelReq.ID)
+	default:
+		return jsonResponse(http.StatusNotFound, []interface{}{"error", 10020, fmt.Sprintf("unknown path %s", path)}), nil
+	}
+	return jsonResponse(http.StatusOK, body), nil
+}
+
+// offerRow encodes offer in the layout of the offer endpoints
+func offerRow(offer data.FundingOffer) []interface{} {
+	return []interface{}{offer.ID, offer.Symbol, offer.CreatedAt.UnixMilli(), offer.UpdatedAt.UnixMilli(),
+		offer.Amount, offer.AmountOriginal, offer.Type, nil, nil, offer.Flags, offer.Status, nil, nil, nil,
+		offer.Rate, offer.Period, 0, 0, nil, 0}
+}
+
+// jsonResponse builds an HTTP response carrying body as JSON
+func jsonResponse(status int, body interface{}) *http.Response {
+	raw, _ := json.Marshal(body)
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(raw)),
+	}
+}
@@ -0,0 +1,108 @@
+package strategy
+
+import (
+	"math"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+// partiallyFilled is the status of a reported fill that left part of the
+// offer working
+const partiallyFilled = "PARTIALLY FILLED"
+
+// fillTracker remembers the offers placed by the runner so it can report
+// them as they are filled
+type fillTracker struct {
+	offers     map[int]data.FundingOffer // Placed offers still open with their unfilled Amount, by ID
+	attributed map[int64]string          // Symbols of credits already reported as a fill, by ID
+}
+
+func newFillTracker() *fillTracker {
+	return &fillTracker{
+		offers:     make(map[int]data.FundingOffer),
+		attributed: make(map[int64]string),
+	}
+}
+
+// track starts watching a placed offer
+func (t *fillTracker) track(offer data.FundingOffer) {
+	t.offers[offer.ID] = offer
+}
+
+// forget stops watching an offer, e.g. because it was cancelled
+func (t *fillTracker) forget(id int) {
+	delete(t.offers, id)
+}
+
+// reconcile returns the fills of the tracked offers of state.Symbol since
+// the last call, matched against new credits. An offer that left the active
+// list is reported with whatever credits remain; one still active whose
+// Amount shrank is reported with Status partiallyFilled and kept tracked
+// for the remainder. Amount and Rate of each returned offer are the filled
+// amount and the amount-weighted realized rate. Offers that left without a
+// matching credit were cancelled or expired and are dropped. When the
+// active offers or credits are unavailable nothing is decided.
+func (t *fillTracker) reconcile(state data.MarketState) []data.FundingOffer {
+	if state.ActiveOffersErr != nil || state.CreditsErr != nil {
+		return nil
+	}
+
+	active := make(map[int]data.FundingOffer, len(state.ActiveOffers))
+	for _, offer := range state.ActiveOffers {
+		active[offer.ID] = offer
+	}
+
+	var filled []data.FundingOffer
+	for id, offer := range t.offers {
+		if offer.Symbol != state.Symbol {
+			continue
+		}
+		remaining, stillActive := active[id]
+		if stillActive {
+			if remaining.Amount >= offer.Amount {
+				continue
+			}
+			t.offers[id] = remaining
+			offer.Status = partiallyFilled
+		} else {
+			delete(t.offers, id)
+		}
+
+		// Credits carry no offer ID, so match them on terms and timing
+		var amount, weighted float64
+		for _, credit := range state.Credits {
+			if t.attributed[credit.ID] != "" || credit.Period != offer.Period ||
+				credit.OpenedAt.Before(offer.CreatedAt) || !sameRate(credit.Rate, offer.Rate) {
+				continue
+			}
+			t.attributed[credit.ID] = state.Symbol
+			amount += credit.Amount
+			weighted += credit.Amount * credit.Rate
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		offer.Amount = amount
+		offer.Rate = weighted / amount
+		filled = append(filled, offer)
+	}
+
+	// Credits that were repaid can no longer be matched
+	current := make(map[int64]bool, len(state.Credits))
+	for _, credit := range state.Credits {
+		current[credit.ID] = true
+	}
+	for id, symbol := range t.attributed {
+		if symbol == state.Symbol && !current[id] {
+			delete(t.attributed, id)
+		}
+	}
+
+	return filled
+}
+
+// sameRate compares daily rates within the precision Bitfinex accepts
+func sameRate(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9
+}
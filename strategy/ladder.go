@@ -0,0 +1,51 @@
+package strategy
+
+import (
+	"github.com/gary/bitfinex-lending-bot/data"
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// BuildLadder splits total into tiers LIMIT offers with rates spread evenly
+// from minRate to maxRate. Amounts are rounded down to decimals and the tier
+// count is reduced when a tier would fall below minTier. Symbol and Flags
+// are left for the caller.
+func BuildLadder(total float64, tiers int, minRate, maxRate float64, period int, minTier float64, decimals int) []data.FundingOfferRequest {
+	if tiers < 1 {
+		tiers = 1
+	}
+
+	// Amounts are split in whole units of the precision so the ladder never
+	// exceeds total
+	totalUnits := util.ToUnits(total, decimals)
+	minUnits := util.ToUnits(minTier, decimals)
+	for tiers > 1 && totalUnits/int64(tiers) < minUnits {
+		tiers--
+	}
+
+	offers := make([]data.FundingOfferRequest, 0, tiers)
+	tierUnits := totalUnits / int64(tiers)
+	remaining := totalUnits
+
+	for i := 0; i < tiers; i++ {
+		rate := minRate
+		if tiers > 1 {
+			rate = minRate + (maxRate-minRate)*float64(i)/float64(tiers-1)
+		}
+
+		// The last tier takes whatever rounding left over
+		units := tierUnits
+		if i == tiers-1 {
+			units = remaining
+		}
+		remaining -= tierUnits
+
+		offers = append(offers, data.FundingOfferRequest{
+			Type:   data.OfferTypeLimit,
+			Amount: util.FormatAmount(util.FromUnits(units, decimals), decimals),
+			Rate:   util.FormatRate(rate),
+			Period: period,
+		})
+	}
+
+	return offers
+}
@@ -0,0 +1,23 @@
+package strategy
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+	"github.com/gary/bitfinex-lending-bot/metrics"
+)
+
+// recordAPIError counts a failed API call by its Bitfinex error code, the
+// HTTP status when there is no code, or "other" for local and network errors
+func recordAPIError(err error) {
+	code := "other"
+	var bfxErr data.BitfinexError
+	if errors.As(err, &bfxErr) {
+		code = bfxErr.ErrorCode
+		if code == "" {
+			code = strconv.Itoa(bfxErr.StatusCode)
+		}
+	}
+	metrics.APIErrors.Inc(code)
+}
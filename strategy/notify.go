@@ -0,0 +1,117 @@
+package strategy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// EventType identifies what a notification is about
+type EventType string
+
+const (
+	EventOfferPlaced    EventType = "offer_placed"
+	EventOfferFilled    EventType = "offer_filled"
+	EventOfferCancelled EventType = "offer_cancelled"
+	EventError          EventType = "error"
+	EventLowBalance     EventType = "low_balance"
+	EventBreakerTripped EventType = "breaker_tripped"
+	EventBreakerReset   EventType = "breaker_reset"
+)
+
+// Event is a notification about something the bot did or ran into. Amount
+// and Rate are zero when they do not apply.
+type Event struct {
+	Type    EventType `json:"type"`
+	Message string    `json:"message"`
+	Symbol  string    `json:"symbol,omitempty"`
+	Amount  float64   `json:"amount,omitempty"`
+	Rate    float64   `json:"rate,omitempty"` // Daily rate
+	Time    time.Time `json:"time"`
+}
+
+// Notifier delivers events to the user, e.g. as push notifications
+type Notifier interface {
+	Notify(event Event) error
+}
+
+// notifyTimeout bounds how long a notification may delay a cycle
+const notifyTimeout = 10 * time.Second
+
+// WebhookNotifier POSTs each event as JSON to a URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: notifyTimeout},
+	}
+}
+
+// Notify implements Notifier
+func (w *WebhookNotifier) Notify(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error serializing event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Repeated error and low balance notifications are collapsed like errorLog
+// does for the log; a low balance tends to persist for days
+const (
+	errorNotifyWindow      = 15 * time.Minute
+	lowBalanceNotifyWindow = 24 * time.Hour
+)
+
+// newThrottledNotifier returns a ThrottledLogger that sends each message it
+// lets through as an event of eventType via cfg.notify
+func newThrottledNotifier(cfg Config, eventType EventType, window time.Duration) *util.ThrottledLogger {
+	t := util.NewThrottledLogger(window)
+	t.SetOutput(func(format string, v ...interface{}) {
+		cfg.notify(Event{Type: eventType, Message: fmt.Sprintf(format, v...)})
+	})
+	return t
+}
+
+// notify sends event through the configured notifier, if any. Delivery
+// failures are logged and otherwise ignored.
+func (c Config) notify(event Event) {
+	if c.Notifier == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if err := c.Notifier.Notify(event); err != nil {
+		errorLog.Printf("Failed to send %s notification: %v", event.Type, err)
+	}
+}
@@ -0,0 +1,117 @@
+package strategy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+// loadPredictOrders reads the tracked predictive orders from path. A missing
+// file means no orders are tracked.
+func loadPredictOrders(path string) ([]CurrentPredictOrder, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading predict orders file: %w", err)
+	}
+
+	var orders []CurrentPredictOrder
+	if err := json.Unmarshal(raw, &orders); err != nil {
+		return nil, fmt.Errorf("error parsing predict orders file: %w", err)
+	}
+
+	// Files written before multi-currency support only tracked fUSD
+	for i := range orders {
+		if orders[i].Symbol == "" {
+			orders[i].Symbol = "fUSD"
+		}
+	}
+	return orders, nil
+}
+
+// savePredictOrders writes the tracked predictive orders to path
+func savePredictOrders(path string, orders []CurrentPredictOrder) error {
+	if orders == nil {
+		orders = []CurrentPredictOrder{}
+	}
+
+	raw, err := json.MarshalIndent(orders, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing predict orders: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("error writing predict orders file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// adoptPredictOrders returns the active offers of symbol that look like
+// predictive offers (same type and period) as tracked orders, and the
+// remaining offers it cannot attribute
+func adoptPredictOrders(symbol string, active []data.FundingOffer, offerType string, period int) ([]CurrentPredictOrder, []data.FundingOffer) {
+	var adopted []CurrentPredictOrder
+	var other []data.FundingOffer
+	for _, offer := range active {
+		if offer.Symbol != symbol {
+			continue
+		}
+		if offer.Type != offerType || offer.Period != period {
+			other = append(other, offer)
+			continue
+		}
+		adopted = append(adopted, CurrentPredictOrder{
+			Symbol:         symbol,
+			ID:             offer.ID,
+			Rate:           offer.Rate,
+			Period:         offer.Period,
+			Since:          offer.CreatedAt,
+			Amount:         offer.Amount,
+			AmountOriginal: offer.AmountOriginal,
+		})
+	}
+	return adopted, other
+}
+
+// reconcilePredictOrders drops tracked orders for symbol that are no longer
+// active on the exchange, e.g. because they were filled or cancelled while
+// the bot was stopped, and updates the amounts of the others to reflect
+// partial fills. Orders for other symbols are kept. changed reports whether
+// anything was updated.
+func reconcilePredictOrders(orders []CurrentPredictOrder, symbol string, active []data.FundingOffer) (kept []CurrentPredictOrder, changed bool) {
+	activeByID := make(map[int]data.FundingOffer, len(active))
+	for _, offer := range active {
+		activeByID[offer.ID] = offer
+	}
+
+	kept = orders[:0]
+	for _, order := range orders {
+		if order.Symbol != symbol {
+			kept = append(kept, order)
+			continue
+		}
+		offer, ok := activeByID[order.ID]
+		if !ok {
+			changed = true
+			continue
+		}
+		if order.Amount != offer.Amount || order.AmountOriginal != offer.AmountOriginal {
+			order.Amount = offer.Amount
+			order.AmountOriginal = offer.AmountOriginal
+			if order.Filled() > 0 {
+				logger.Infof("Predictive order %d partially filled: %.2f of %.2f lent, %.2f still offered",
+					order.ID, order.Filled(), order.AmountOriginal, order.Amount)
+			}
+			changed = true
+		}
+		kept = append(kept, order)
+	}
+	return kept, changed
+}
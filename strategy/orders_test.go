@@ -0,0 +1,52 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+func TestDecideAdoptsOnlyDistinctPredictOffers(t *testing.T) {
+	active := []data.FundingOffer{
+		{ID: 1, Symbol: "fUSD", Amount: 200, Type: data.OfferTypeLimit, Rate: 0.0002, Period: 2},
+		{ID: 2, Symbol: "fUSD", Amount: 200, Type: data.OfferTypeFRRDeltaVar, Rate: 0.00001, Period: 2},
+		{ID: 3, Symbol: "fUSD", Amount: 200, Type: data.OfferTypeLimit, Rate: 0.0002, Period: 30},
+	}
+
+	tests := []struct {
+		name           string
+		mode           PredictMode
+		fix            float64
+		allowedPeriods []int
+		wantAdopted    []int
+	}{
+		{"frr delta offers", PredictModeFRRDelta, 0.5, nil, []int{2}},
+		{"limit without fixed leg", PredictModeFixed, 0, nil, []int{1}},
+		{"limit with any fixed period", PredictModeFixed, 0.5, nil, nil},
+		{"limit with allowed periods", PredictModeFixed, 0.5, []int{2, 30}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.PredictMode = tt.mode
+			cfg.Distribution = Distribution{Fix: tt.fix, Predict: 1 - tt.fix}
+			cfg.AllowedPeriods = tt.allowedPeriods
+			s := NewDefaultStrategy(cfg)
+
+			state := data.MarketState{Symbol: "fUSD", TotalBalance: 600, ActiveOffers: active}
+			if _, _, err := s.Decide(context.Background(), state); err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+
+			var adopted []int
+			for _, order := range s.currentPredictOrder {
+				adopted = append(adopted, order.ID)
+			}
+			if len(adopted) != len(tt.wantAdopted) || (len(adopted) > 0 && adopted[0] != tt.wantAdopted[0]) {
+				t.Errorf("adopted %v, want %v", adopted, tt.wantAdopted)
+			}
+		})
+	}
+}
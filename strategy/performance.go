@@ -0,0 +1,133 @@
+package strategy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+// PerformanceTracker accumulates long-term bot statistics and persists
+// them across restarts
+type PerformanceTracker struct {
+	StartedAt      time.Time     `json:"started_at"`      // First cycle ever recorded
+	LastCycleAt    time.Time     `json:"last_cycle_at"`   // Most recent cycle
+	Uptime         time.Duration `json:"uptime"`          // Time covered by consecutive cycles
+	CyclesRun      int           `json:"cycles_run"`      // Number of completed cycles
+	OffersPlaced   int           `json:"offers_placed"`   // Number of successfully submitted offers
+	InterestEarned float64       `json:"interest_earned"` // Total interest earned
+	PrincipalDays  float64       `json:"principal_days"`  // Sum of principal * days lent
+
+	// InterestSince is the time of the last interest payment recorded per
+	// currency, so payments are counted once across restarts
+	InterestSince map[string]time.Time `json:"interest_since"`
+
+	path string
+}
+
+// LoadPerformanceTracker reads the tracker from path, returning an empty
+// tracker on first run
+func LoadPerformanceTracker(path string) (*PerformanceTracker, error) {
+	t := &PerformanceTracker{path: path}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading performance file: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, t); err != nil {
+		return nil, fmt.Errorf("error parsing performance file: %w", err)
+	}
+	return t, nil
+}
+
+// Save writes the tracker to its file
+func (t *PerformanceTracker) Save() error {
+	raw, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing performance data: %w", err)
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("error writing performance file: %w", err)
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// RecordCycle counts a completed cycle. Gaps longer than maxGap (e.g. while
+// the bot was stopped) are not counted as uptime.
+func (t *PerformanceTracker) RecordCycle(now time.Time, maxGap time.Duration) {
+	if t.StartedAt.IsZero() {
+		t.StartedAt = now
+	}
+	if !t.LastCycleAt.IsZero() {
+		if gap := now.Sub(t.LastCycleAt); gap > 0 && gap <= maxGap {
+			t.Uptime += gap
+		}
+	}
+	t.LastCycleAt = now
+	t.CyclesRun++
+}
+
+// RecordOffer counts a successfully submitted offer
+func (t *PerformanceTracker) RecordOffer() {
+	t.OffersPlaced++
+}
+
+// RecordInterest adds realized interest earned on principal lent for days
+func (t *PerformanceTracker) RecordInterest(interest, principal, days float64) {
+	t.InterestEarned += interest
+	t.PrincipalDays += principal * days
+}
+
+// RecordPayments records the interest payments of currency newer than the
+// last one recorded. Bitfinex pays funding interest daily, so each payment
+// counts principal, the amount currently lent, for one day. The first call
+// for a currency only marks the newest payment, so interest earned before
+// the bot ran is not counted.
+func (t *PerformanceTracker) RecordPayments(currency string, payments []data.LedgerEntry, principal float64) {
+	if t.InterestSince == nil {
+		t.InterestSince = make(map[string]time.Time)
+	}
+	since, ok := t.InterestSince[currency]
+
+	newest := since
+	for _, payment := range payments {
+		if !payment.CreatedAt.After(since) {
+			continue
+		}
+		if ok {
+			t.RecordInterest(payment.Amount, principal, 1)
+		}
+		if payment.CreatedAt.After(newest) {
+			newest = payment.CreatedAt
+		}
+	}
+	t.InterestSince[currency] = newest
+}
+
+// RealizedAPR returns the average annual rate realized so far
+func (t *PerformanceTracker) RealizedAPR() float64 {
+	if t.PrincipalDays == 0 {
+		return 0
+	}
+	return data.DailyToAnnual(t.InterestEarned / t.PrincipalDays)
+}
+
+// Summary returns a human-readable scorecard
+func (t *PerformanceTracker) Summary() string {
+	if t.CyclesRun == 0 {
+		return "No cycles recorded yet"
+	}
+	return fmt.Sprintf("Since %s: %d cycles, %d offers placed, uptime %s, interest earned %.2f, realized APR %.2f%%",
+		t.StartedAt.Format(time.RFC3339), t.CyclesRun, t.OffersPlaced,
+		t.Uptime.Round(time.Second), t.InterestEarned, t.RealizedAPR()*100)
+}
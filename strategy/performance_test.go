@@ -0,0 +1,71 @@
+package strategy
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+func TestPerformanceTrackerPersists(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	payment := func(hours int, amount float64) data.LedgerEntry {
+		return data.LedgerEntry{ID: int64(hours), Currency: "USD", CreatedAt: start.Add(time.Duration(hours) * time.Hour), Amount: amount}
+	}
+
+	tests := []struct {
+		name         string
+		update       func(p *PerformanceTracker)
+		wantCycles   int
+		wantOffers   int
+		wantInterest float64
+	}{
+		{"first run is empty", func(p *PerformanceTracker) {}, 0, 0, 0},
+		{"cycles and offers", func(p *PerformanceTracker) {
+			p.RecordCycle(start, time.Hour)
+			p.RecordCycle(start.Add(time.Minute), time.Hour)
+			p.RecordOffer()
+		}, 2, 1, 0},
+		{"payments before the first cycle are not counted", func(p *PerformanceTracker) {
+			p.RecordPayments("USD", []data.LedgerEntry{payment(0, 5)}, 1000)
+		}, 0, 0, 0},
+		{"new payments are counted once", func(p *PerformanceTracker) {
+			p.RecordPayments("USD", []data.LedgerEntry{payment(0, 5)}, 1000)
+			p.RecordPayments("USD", []data.LedgerEntry{payment(24, 0.5), payment(0, 5)}, 1000)
+			p.RecordPayments("USD", []data.LedgerEntry{payment(24, 0.5)}, 1000)
+		}, 0, 0, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "performance.json")
+			p, err := LoadPerformanceTracker(path)
+			if err != nil {
+				t.Fatalf("LoadPerformanceTracker: %v", err)
+			}
+			tt.update(p)
+			if err := p.Save(); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			reloaded, err := LoadPerformanceTracker(path)
+			if err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			if reloaded.CyclesRun != tt.wantCycles || reloaded.OffersPlaced != tt.wantOffers || reloaded.InterestEarned != tt.wantInterest {
+				t.Errorf("reloaded %d cycles, %d offers, %v interest; want %d, %d, %v",
+					reloaded.CyclesRun, reloaded.OffersPlaced, reloaded.InterestEarned, tt.wantCycles, tt.wantOffers, tt.wantInterest)
+			}
+			if !reloaded.StartedAt.Equal(p.StartedAt) || reloaded.Uptime != p.Uptime || !reloaded.InterestSince["USD"].Equal(p.InterestSince["USD"]) {
+				t.Errorf("reloaded %+v, want %+v", reloaded, p)
+			}
+
+			// Payments counted before the restart are not counted again
+			reloaded.RecordPayments("USD", []data.LedgerEntry{payment(24, 0.5), payment(0, 5)}, 1000)
+			if tt.wantInterest > 0 && reloaded.InterestEarned != tt.wantInterest {
+				t.Errorf("interest after replaying a counted payment = %v, want %v", reloaded.InterestEarned, tt.wantInterest)
+			}
+		})
+	}
+}
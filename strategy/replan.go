@@ -0,0 +1,88 @@
+package strategy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+	"github.com/gary/bitfinex-lending-bot/util.go"
+)
+
+// runState holds what belongs to a single Run, so that several runs in one
+// process do not share replan signals, subscriptions or notifiers
+type runState struct {
+	// replan wakes the strategy loop before the regular interval elapses
+	replan chan struct{}
+
+	// walletSub is the live wallet subscription, nil until first subscribed
+	walletSub *data.WalletSubscription
+
+	errorNotify      *util.ThrottledLogger
+	lowBalanceNotify *util.ThrottledLogger
+}
+
+// newRunState creates the state of a run notifying through cfg
+func newRunState(cfg Config) *runState {
+	return &runState{
+		replan:           make(chan struct{}, 1),
+		errorNotify:      newThrottledNotifier(cfg, EventError, errorNotifyWindow),
+		lowBalanceNotify: newThrottledNotifier(cfg, EventLowBalance, lowBalanceNotifyWindow),
+	}
+}
+
+// requestReplan asks the strategy loop to run its next cycle immediately
+func (r *runState) requestReplan() {
+	select {
+	case r.replan <- struct{}{}:
+	default:
+	}
+}
+
+// stopWalletWatch closes the wallet subscription, if any
+func (r *runState) stopWalletWatch() {
+	if r.walletSub != nil {
+		r.walletSub.Close()
+		r.walletSub = nil
+	}
+}
+
+// ensureWalletWatch (re)subscribes to funding wallet updates so that a
+// deposit triggers a replan. Rapid updates are debounced.
+func (r *runState) ensureWalletWatch(client *data.Client, debounce time.Duration) {
+	if r.walletSub != nil {
+		select {
+		case <-r.walletSub.Done():
+		default:
+			return // Still connected
+		}
+	}
+
+	var (
+		mu          sync.Mutex
+		timer       *time.Timer
+		lastBalance = make(map[string]float64)
+	)
+
+	sub, err := client.SubscribeWallets(func(w data.Wallet) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Only deposits (balance increases) warrant a replan; placing
+		// offers changes the available balance, not the balance
+		prev, seen := lastBalance[w.Currency]
+		lastBalance[w.Currency] = w.Balance
+		if !seen || w.Balance <= prev {
+			return
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(debounce, r.requestReplan)
+	})
+	if err != nil {
+		errorLog.Printf("Failed to subscribe to wallet updates: %v", err)
+		return
+	}
+	r.walletSub = sub
+}
@@ -0,0 +1,72 @@
+package strategy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+	"github.com/gary/bitfinex-lending-bot/util.go"
+	"github.com/gorilla/websocket"
+)
+
+func TestWalletUpdateTriggersReplan(t *testing.T) {
+	snapshot := `[0,"ws",[["funding","USD",1000,0,1000,null,null],["exchange","USD",50,0,50,null,null]]]`
+
+	tests := []struct {
+		name       string
+		messages   []string
+		wantReplan bool
+	}{
+		{"deposit", []string{snapshot, `[0,"wu",["funding","USD",1500,0,1500,null,null]]`}, true},
+		{"offer placed", []string{snapshot, `[0,"wu",["funding","USD",1000,0,200,null,null]]`}, false},
+		{"exchange wallet deposit", []string{snapshot, `[0,"wu",["exchange","USD",500,0,500,null,null]]`}, false},
+		{"snapshot only", []string{snapshot}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			sent := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				if _, _, err := conn.ReadMessage(); err != nil { // Auth request
+					return
+				}
+				for _, msg := range tt.messages {
+					conn.WriteMessage(websocket.TextMessage, []byte(msg))
+				}
+				close(sent)
+				conn.ReadMessage() // Hold the connection until the client closes it
+			}))
+			defer srv.Close()
+
+			client := data.NewClient("key", "secret", data.WithLogger(util.NopLogger{}),
+				data.WithWSAuthURL("ws"+strings.TrimPrefix(srv.URL, "http")))
+			run := newRunState(testConfig())
+			run.ensureWalletWatch(client, 10*time.Millisecond)
+			defer run.stopWalletWatch()
+			if run.walletSub == nil {
+				t.Fatal("wallet subscription failed")
+			}
+			<-sent
+
+			select {
+			case <-run.replan:
+				if !tt.wantReplan {
+					t.Error("replan requested, want none")
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantReplan {
+					t.Error("no replan requested, want one")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+	"github.com/gary/bitfinex-lending-bot/metrics"
+)
+
+// Run executes s every cfg.Interval until ctx is cancelled. A failed cycle
+// is logged and retried on the next tick; after BreakerThreshold failed
+// cycles in a row trading halts until the API recovers.
+func Run(ctx context.Context, cfg Config, s Strategy) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	if cfg.Notifier == nil && cfg.NotifyWebhookURL != "" {
+		cfg.Notifier = NewWebhookNotifier(cfg.NotifyWebhookURL)
+	}
+	run := newRunState(cfg)
+
+	// Create API client
+	client, err := data.NewClientE(cfg.APIKey, cfg.APISecret, data.WithLogger(logger))
+	if err != nil {
+		return fmt.Errorf("failed to create API client: %w", err)
+	}
+	defer client.Close()
+
+	// Fail fast on bad credentials or no connectivity
+	if err := client.PingContext(ctx); err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnauthorized):
+			return fmt.Errorf("API key rejected, check BITFINEX_API_KEY and BITFINEX_API_SECRET: %w", err)
+		case errors.Is(err, data.ErrUnreachable):
+			return fmt.Errorf("cannot reach Bitfinex, check your network connection: %w", err)
+		default:
+			return fmt.Errorf("startup check failed: %w", err)
+		}
+	}
+
+	if cfg.MetricsAddr != "" {
+		go func() {
+			if err := metrics.ListenAndServe(ctx, cfg.MetricsAddr); err != nil {
+				logger.Errorf("%v", err)
+			}
+		}()
+	}
+
+	// Load cumulative performance stats
+	perf, err := LoadPerformanceTracker(cfg.PerformanceFile)
+	if err != nil {
+		errorLog.Printf("Failed to load performance stats: %v", err)
+		perf = &PerformanceTracker{path: cfg.PerformanceFile}
+	}
+
+	fills := newFillTracker()
+	breaker := newCircuitBreaker(cfg)
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	defer run.stopWalletWatch()
+
+	for {
+		// Replan immediately when funds are deposited
+		run.ensureWalletWatch(client, cfg.ReplanDebounce)
+
+		if breaker.allow(ctx, client, cfg) {
+			err := runCycle(ctx, run, client, cfg, s, perf, fills)
+			if err != nil {
+				errorLog.Printf("Strategy cycle failed: %v", err)
+			}
+			if ctx.Err() == nil {
+				breaker.record(cfg, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if cfg.CancelOnShutdown {
+				cancelManagedOffers(client, cfg, s)
+			}
+			return nil
+		case <-ticker.C:
+		case <-run.replan:
+			logger.Infof("Wallet balance increased, replanning now")
+		}
+	}
+}
+
+// shutdownTimeout bounds how long cancelling offers may delay exit
+const shutdownTimeout = 10 * time.Second
+
+// cancelManagedOffers cancels the offers s actively manages, if any
+func cancelManagedOffers(client *data.Client, cfg Config, s Strategy) {
+	manager, ok := s.(OfferManager)
+	if !ok {
+		return
+	}
+
+	// The run context is already cancelled, so use a fresh bounded one
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, id := range manager.ManagedOffers() {
+		cancelled, err := cancelOffer(ctx, client, cfg, id)
+		if err != nil {
+			errorLog.Printf("Failed to cancel order on shutdown (ID: %d): %v", id, err)
+			continue
+		}
+		if cancelled != nil {
+			logger.Infof("Cancelled lending order on shutdown: ID=%d, %.2f %s @ daily rate %.6f",
+				cancelled.ID, cancelled.Amount, cancelled.Symbol, cancelled.Rate)
+		}
+	}
+}
+
+// runCycle runs the strategy once for every configured symbol. It fails
+// only when every symbol failed.
+func runCycle(ctx context.Context, run *runState, client *data.Client, cfg Config, s Strategy, perf *PerformanceTracker, fills *fillTracker) error {
+	// Record this cycle when it ends
+	start := time.Now()
+	defer func() {
+		metrics.CycleDuration.Set(time.Since(start).Seconds())
+		perf.RecordCycle(time.Now(), 2*cfg.Interval)
+		if err := perf.Save(); err != nil {
+			errorLog.Printf("Failed to save performance stats: %v", err)
+		}
+		logger.Infof("%s", perf.Summary())
+	}()
+
+	// Each currency is planned independently; one failing does not stop
+	// the others
+	var errs []error
+	for _, symbol := range cfg.Symbols {
+		if err := runSymbol(ctx, run, client, cfg, s, perf, fills, symbol); err != nil {
+			errorLog.Printf("Strategy cycle for %s failed: %v", symbol, err)
+			run.errorNotify.Printf("Strategy cycle for %s failed: %v", symbol, err)
+			errs = append(errs, fmt.Errorf("%s: %w", symbol, err))
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(cfg.Symbols) {
+		return fmt.Errorf("every symbol failed: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// runSymbol fetches the market state for symbol, asks s for a decision and
+// executes it
+func runSymbol(ctx context.Context, run *runState, client *data.Client, cfg Config, s Strategy, perf *PerformanceTracker, fills *fillTracker, symbol string) error {
+	// Check the balance first so a dust balance costs a single request
+	wallets, err := client.GetWalletsDetailedContext(ctx)
+	if err != nil {
+		recordAPIError(err)
+		return fmt.Errorf("error getting wallets: %w", err)
+	}
+	wallet, _ := data.FundingWallet(wallets, symbol)
+	if minBalance := cfg.minTotalBalance(symbol); wallet.Balance < minBalance {
+		logger.Debugf("Skipping %s: balance %.2f is below minimum %.2f", symbol, wallet.Balance, minBalance)
+		run.lowBalanceNotify.Printf("%s funding balance is below the minimum of %.2f, lending is paused", symbol, minBalance)
+		return nil
+	}
+
+	state := client.GetMarketStateWithWalletsContext(ctx, symbol, wallets)
+	metrics.DeployedCapital.Set(symbol, state.Lent()+state.Offered())
+	currency := state.Currency()
+	logger.Infof("Total balance: %.2f %s, available: %.2f %s, unsettled interest: %.2f %s",
+		state.TotalBalance, currency, state.AvailableBalance, currency, state.UnsettledInterest, currency)
+
+	// Count the interest paid since the last cycle
+	recordInterestPayments(ctx, client, perf, state)
+
+	if state.ActiveOffersErr != nil {
+		errorLog.Printf("Failed to get active offers: %v", state.ActiveOffersErr)
+	}
+	if state.CreditsErr != nil {
+		errorLog.Printf("Failed to get funding credits, estimating lent amount: %v", state.CreditsErr)
+	}
+	if state.BookErr != nil {
+		errorLog.Printf("Error getting book: %v", state.BookErr)
+	}
+	if state.StatsErr != nil {
+		errorLog.Printf("Failed to get funding statistics: %v", state.StatsErr)
+	}
+
+	for _, filled := range fills.reconcile(*state) {
+		what := "filled"
+		if filled.Status == partiallyFilled {
+			what = "partially filled"
+		} else {
+			metrics.OffersFilled.Inc()
+		}
+		logger.Infof("Lending order %s: ID=%d, %.2f %s @ daily rate %.6f for %d days",
+			what, filled.ID, filled.Amount, currency, filled.Rate, filled.Period)
+		if cfg.OnFill != nil {
+			cfg.OnFill(filled)
+		}
+		cfg.notify(Event{
+			Type:    EventOfferFilled,
+			Message: fmt.Sprintf("Lending order %d %s", filled.ID, what),
+			Symbol:  symbol,
+			Amount:  filled.Amount,
+			Rate:    filled.Rate,
+		})
+	}
+
+	offers, cancels, err := s.Decide(ctx, *state)
+	if err != nil {
+		return fmt.Errorf("strategy decision failed: %w", err)
+	}
+
+	// Cancel first so the freed capital and offer slots can be reused
+	openOffers := len(state.ActiveOffers)
+	budget := state.AvailableBalance - cfg.cashReserve(state.TotalBalance) // Minimum bumps never touch the reserve
+	for _, id := range cancels {
+		cancelled, err := cancelOffer(ctx, client, cfg, id)
+		if err != nil {
+			errorLog.Printf("Failed to cancel order (ID: %d): %v", id, err)
+			recordAPIError(err)
+			continue
+		}
+		fills.forget(id)
+		metrics.OffersCancelled.Inc()
+		if cancelled != nil {
+			logger.Infof("Cancelled lending order: ID=%d, %.2f %s @ daily rate %.6f for %d days",
+				cancelled.ID, cancelled.Amount, currency, cancelled.Rate, cancelled.Period)
+			cfg.notify(Event{
+				Type:    EventOfferCancelled,
+				Message: fmt.Sprintf("Lending order %d cancelled", cancelled.ID),
+				Symbol:  symbol,
+				Amount:  cancelled.Amount,
+				Rate:    cancelled.Rate,
+			})
+		}
+		for _, offer := range state.ActiveOffers {
+			if offer.ID == id {
+				budget += offer.Amount
+				openOffers--
+				break
+			}
+		}
+	}
+
+	// Without the open offers the cap cannot be enforced, and Bitfinex
+	// rejects offers beyond its own limit with an opaque error
+	if cfg.MaxOpenOffers > 0 && state.ActiveOffersErr != nil && len(offers) > 0 {
+		logger.Warnf("Open %s offers unknown, skipping %d new offers to stay under the max of %d",
+			symbol, len(offers), cfg.MaxOpenOffers)
+		return nil
+	}
+
+	for i, offer := range offers {
+		if !cfg.canPlaceOffer(openOffers) {
+			logger.Warnf("Max open offers (%d) reached for %s, skipping %d remaining offers",
+				cfg.MaxOpenOffers, symbol, len(offers)-i)
+			break
+		}
+
+		if i > 0 && cfg.InterOrderDelay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(cfg.InterOrderDelay + client.Jitter(cfg.InterOrderDelay/2)):
+			}
+		}
+
+		logger.Infof("Submitting lending order: %s %s @ daily rate %s for %d days",
+			offer.Amount, currency, offer.Rate, offer.Period)
+
+		res, err := submitOffer(ctx, client, cfg, offer, budget)
+		if err != nil {
+			errorLog.Printf("Failed to submit lending order: %v", err)
+			recordAPIError(err)
+			continue
+		}
+		budget -= res.Amount
+		openOffers++
+		perf.RecordOffer()
+		metrics.OffersPlaced.Inc()
+		logger.Infof("Successfully submitted lending order: ID=%d, Status=%s", res.ID, res.Status)
+		if !cfg.DryRun {
+			fills.track(*res)
+		}
+		cfg.notify(Event{
+			Type:    EventOfferPlaced,
+			Message: fmt.Sprintf("Lending order %d placed for %d days", res.ID, res.Period),
+			Symbol:  symbol,
+			Amount:  res.Amount,
+			Rate:    res.Rate,
+		})
+
+		if observer, ok := s.(OfferObserver); ok {
+			observer.OfferPlaced(offer, res)
+		}
+	}
+
+	return nil
+}
+
+// recordInterestPayments adds the interest payments of the state's currency
+// since the last recorded one to perf. A failed lookup is retried on the
+// next cycle.
+func recordInterestPayments(ctx context.Context, client *data.Client, perf *PerformanceTracker, state *data.MarketState) {
+	currency := state.Currency()
+
+	var start int64
+	if since := perf.InterestSince[currency]; !since.IsZero() {
+		start = since.UnixMilli()
+	}
+	payments, err := client.GetLedgersContext(ctx, currency, data.LedgerCategoryInterestPayment, start, 0, 0)
+	if err != nil {
+		errorLog.Printf("Failed to get %s interest payments: %v", currency, err)
+		return
+	}
+	perf.RecordPayments(currency, payments, state.Lent())
+}
@@ -0,0 +1,206 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+func TestRunSymbolRelendsFreedCapital(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	exchange := newFakeExchange("fUSD", 1000, 0.0002, now)
+	client := exchange.newClient()
+
+	var fills []data.FundingOffer
+	cfg := testConfig()
+	cfg.OnFill = func(offer data.FundingOffer) { fills = append(fills, offer) }
+	s := newTestStrategy(cfg)
+	s.now = now
+	perf := &PerformanceTracker{}
+	tracker := newFillTracker()
+
+	steps := []struct {
+		name       string
+		before     func()
+		wantFills  int
+		wantAmount string // Amount of the offer submitted this cycle; empty for none
+	}{
+		{"idle balance is lent", nil, 0, "1000.00"},
+		{"fill is reported and nothing is re-lent", func() { exchange.fill(101) }, 1, ""},
+		{"repaid credit is lent again", func() { exchange.repay(1.5) }, 0, "1001.50"},
+	}
+
+	for _, step := range steps {
+		clock = clock.Add(cfg.Interval)
+		if step.before != nil {
+			step.before()
+		}
+		fills = nil
+		submitted := len(exchange.submitted)
+
+		if err := runSymbol(context.Background(), newRunState(cfg), client, cfg, s, perf, tracker, "fUSD"); err != nil {
+			t.Fatalf("%s: runSymbol: %v", step.name, err)
+		}
+
+		if len(fills) != step.wantFills {
+			t.Errorf("%s: %d fills reported, want %d", step.name, len(fills), step.wantFills)
+		}
+		placed := exchange.submitted[submitted:]
+		switch {
+		case step.wantAmount == "" && len(placed) != 0:
+			t.Errorf("%s: submitted %+v, want nothing", step.name, placed)
+		case step.wantAmount != "" && (len(placed) != 1 || placed[0].Amount != step.wantAmount):
+			t.Errorf("%s: submitted %+v, want one offer of %s", step.name, placed, step.wantAmount)
+		}
+	}
+
+	if perf.InterestEarned != 1.5 {
+		t.Errorf("interest earned = %v, want 1.5", perf.InterestEarned)
+	}
+	if len(s.currentPredictOrder) != 1 || s.currentPredictOrder[0].ID != 102 {
+		t.Errorf("tracked orders = %+v, want only the re-lent offer 102", s.currentPredictOrder)
+	}
+}
+
+func TestRunSymbolSkipsDustBalance(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name            string
+		balance         float64
+		wantOnlyWallets bool
+	}{
+		{"below minimum", 100, true},
+		{"at minimum", 150, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := newFakeExchange("fUSD", tt.balance, 0.0002, now)
+			cfg := testConfig()
+			s := newTestStrategy(cfg)
+
+			if err := runSymbol(context.Background(), newRunState(cfg), exchange.newClient(), cfg, s, &PerformanceTracker{}, newFillTracker(), "fUSD"); err != nil {
+				t.Fatalf("runSymbol: %v", err)
+			}
+			onlyWallets := len(exchange.requests) == 1 && exchange.requests[0] == "/v2/auth/r/wallets"
+			if onlyWallets != tt.wantOnlyWallets {
+				t.Errorf("requests = %v, want only the wallets: %v", exchange.requests, tt.wantOnlyWallets)
+			}
+		})
+	}
+}
+
+func TestRunSymbolSubmitsConfiguredFlags(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name      string
+		flags     data.FundingFlags
+		hidden    bool
+		wantFlags int
+	}{
+		{"none", 0, false, 0},
+		{"hidden", 0, true, 64},
+		{"close", data.FundingFlagClose, false, 512},
+		{"no var rates and hidden", data.FundingFlagNoVarRates, true, 524352},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := newFakeExchange("fUSD", 1000, 0.0002, now)
+			cfg := testConfig()
+			cfg.OfferFlags = tt.flags
+			cfg.UseHiddenOffers = tt.hidden
+			s := newTestStrategy(cfg)
+
+			if err := runSymbol(context.Background(), newRunState(cfg), exchange.newClient(), cfg, s, &PerformanceTracker{}, newFillTracker(), "fUSD"); err != nil {
+				t.Fatalf("runSymbol: %v", err)
+			}
+			if len(exchange.submitted) != 1 || exchange.submitted[0].Flags != tt.wantFlags {
+				t.Errorf("submitted %+v, want one offer with flags %d", exchange.submitted, tt.wantFlags)
+			}
+		})
+	}
+}
+
+// recordingNotifier collects the events it is sent
+type recordingNotifier struct {
+	events []Event
+}
+
+func (n *recordingNotifier) Notify(event Event) error {
+	n.events = append(n.events, event)
+	return nil
+}
+
+func TestRunStatesNotifyIndependently(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	// Two runs in one process, each with its own notifier; the second run
+	// must neither redirect nor throttle the first run's notifications
+	notifiers := []*recordingNotifier{{}, {}}
+	runs := make([]*runState, len(notifiers))
+	for i, n := range notifiers {
+		cfg := testConfig()
+		cfg.Notifier = n
+		runs[i] = newRunState(cfg)
+	}
+
+	for i, run := range runs {
+		exchange := newFakeExchange("fUSD", 100, 0.0002, now)
+		cfg := testConfig()
+		if err := runSymbol(context.Background(), run, exchange.newClient(), cfg, newTestStrategy(cfg), &PerformanceTracker{}, newFillTracker(), "fUSD"); err != nil {
+			t.Fatalf("run %d: runSymbol: %v", i, err)
+		}
+	}
+
+	for i, n := range notifiers {
+		if len(n.events) != 1 || n.events[0].Type != EventLowBalance {
+			t.Errorf("run %d notified %+v, want one low balance event", i, n.events)
+		}
+	}
+}
+
+func TestRunSymbolRetriesAtExchangeMinimum(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	// Half of the 1000 balance is planned for the predictive leg; the book
+	// is empty so the fixed half stays free as budget for a bump
+	tests := []struct {
+		name         string
+		minimum      float64
+		wantRejected int
+		wantAmount   string // Amount of the placed offer; empty for none
+	}{
+		{"above the minimum", 150, 0, "500.00"},
+		{"bumped to the minimum", 600, 1, "600"},
+		{"minimum over budget", 1200, 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := newFakeExchange("fUSD", 1000, 0.0002, now)
+			exchange.minimum = tt.minimum
+			cfg := testConfig()
+			cfg.Distribution = Distribution{Fix: 0.5, Predict: 0.5}
+			s := newTestStrategy(cfg)
+
+			if err := runSymbol(context.Background(), newRunState(cfg), exchange.newClient(), cfg, s, &PerformanceTracker{}, newFillTracker(), "fUSD"); err != nil {
+				t.Fatalf("runSymbol: %v", err)
+			}
+			if len(exchange.rejected) != tt.wantRejected {
+				t.Errorf("rejected %+v, want %d", exchange.rejected, tt.wantRejected)
+			}
+			switch {
+			case tt.wantAmount == "" && len(exchange.submitted) != 0:
+				t.Errorf("placed %+v, want nothing", exchange.submitted)
+			case tt.wantAmount != "" && (len(exchange.submitted) != 1 || exchange.submitted[0].Amount != tt.wantAmount):
+				t.Errorf("placed %+v, want one offer of %s", exchange.submitted, tt.wantAmount)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package strategy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gary/bitfinex-lending-bot/data"
+)
+
+// dryRunID hands out fake offer IDs in dry-run mode; they are negative so
+// they can never collide with real exchange IDs
+var dryRunID atomic.Int64
+
+// submitOffer submits an offer. If the exchange rejects it for being below
+// its minimum, the amount is bumped to the reported minimum and retried once
+// when that fits within budget; otherwise the offer is skipped. In dry-run
+// mode the offer is only logged.
+func submitOffer(ctx context.Context, client *data.Client, cfg Config, offer data.FundingOfferRequest, budget float64) (*data.FundingOffer, error) {
+	if cfg.DryRun {
+		if err := offer.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid funding offer: %w", err)
+		}
+		return dryRunSubmit(offer), nil
+	}
+
+	submitted := time.Now()
+	res, err := client.SubmitFundingOfferContext(ctx, offer)
+	if submitOutcomeUnknown(err) {
+		if placed := findSubmittedOffer(ctx, client, offer, submitted); placed != nil {
+			return placed, nil
+		}
+	}
+
+	var minErr *data.OfferMinimumError
+	if !errors.As(err, &minErr) {
+		return res, err
+	}
+
+	logger.Warnf("Offer of %s rejected: exchange minimum is %.2f", offer.Amount, minErr.Minimum)
+	if minErr.Minimum <= 0 || minErr.Minimum > budget {
+		return nil, err
+	}
+
+	offer.Amount = strconv.FormatFloat(minErr.Minimum, 'f', -1, 64)
+	logger.Infof("Retrying offer with minimum amount %s", offer.Amount)
+	return client.SubmitFundingOfferContext(ctx, offer)
+}
+
+// submitOutcomeUnknown reports whether a failed submission may still have
+// been processed by the exchange: the connection failed or timed out, or a
+// gateway answered with a server error
+func submitOutcomeUnknown(err error) bool {
+	if err == nil {
+		return false
+	}
+	var bfxErr data.BitfinexError
+	if errors.As(err, &bfxErr) {
+		return bfxErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// submitClockSkew bounds how long before the submission an offer may have
+// been created by the exchange's clock and still be taken for it
+const submitClockSkew = time.Minute
+
+// findSubmittedOffer looks for the offer a failed submission started at
+// submitted may have placed, so it is tracked instead of being submitted
+// twice. Offers created before the submission are ignored so an identical
+// older offer is not mistaken for it.
+func findSubmittedOffer(ctx context.Context, client *data.Client, offer data.FundingOfferRequest, submitted time.Time) *data.FundingOffer {
+	since := submitted.Add(-submitClockSkew)
+	amount, _ := strconv.ParseFloat(offer.Amount, 64)
+	rate, _ := strconv.ParseFloat(offer.Rate, 64)
+
+	placed, found, err := client.FindOfferByParamsContext(ctx, offer.Symbol, amount, rate, offer.Period)
+	if err != nil {
+		logger.Warnf("Could not check whether the failed offer of %s was placed: %v", offer.Amount, err)
+		return nil
+	}
+	if !found || placed.CreatedAt.Before(since) {
+		return nil
+	}
+
+	logger.Warnf("Offer of %s failed to submit but was placed as ID=%d", offer.Amount, placed.ID)
+	return placed
+}
+
+// cancelOffer cancels an offer and returns it as cancelled, or only logs the
+// cancellation in dry-run mode (returning a nil offer)
+func cancelOffer(ctx context.Context, client *data.Client, cfg Config, offerID int) (*data.FundingOffer, error) {
+	if cfg.DryRun {
+		logger.Infof("[dry run] Would cancel funding offer ID=%d", offerID)
+		return nil, nil
+	}
+	return client.CancelFundingOfferContext(ctx, offerID)
+}
+
+// dryRunSubmit logs the offer payload and returns a synthetic offer as if
+// the exchange had accepted it
+func dryRunSubmit(offer data.FundingOfferRequest) *data.FundingOffer {
+	payload, _ := json.Marshal(offer)
+	logger.Infof("[dry run] Would submit funding offer: %s", payload)
+
+	amount, _ := strconv.ParseFloat(offer.Amount, 64)
+	rate, _ := strconv.ParseFloat(offer.Rate, 64)
+	now := time.Now().UTC()
+
+	return &data.FundingOffer{
+		ID:             int(dryRunID.Add(-1)),
+		Symbol:         offer.Symbol,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Amount:         amount,
+		AmountOriginal: amount,
+		Type:           offer.Type,
+		Flags:          offer.Flags,
+		Status:         "ACTIVE",
+		Rate:           rate,
+		Period:         offer.Period,
+	}
+}
@@ -0,0 +1,134 @@
+package util
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Logger is a leveled logger. Implement it to route output to a structured
+// or JSON logging backend.
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// StdLogger writes leveled messages through a standard library logger
+type StdLogger struct {
+	l *log.Logger
+}
+
+// NewStdLogger creates a StdLogger writing to l, or to the default logger
+// when l is nil
+func NewStdLogger(l *log.Logger) *StdLogger {
+	if l == nil {
+		l = log.Default()
+	}
+	return &StdLogger{l: l}
+}
+
+// Debugf logs a debug message
+func (s *StdLogger) Debugf(format string, v ...interface{}) { s.l.Printf("DEBUG "+format, v...) }
+
+// Infof logs an informational message
+func (s *StdLogger) Infof(format string, v ...interface{}) { s.l.Printf("INFO "+format, v...) }
+
+// Warnf logs a warning
+func (s *StdLogger) Warnf(format string, v ...interface{}) { s.l.Printf("WARN "+format, v...) }
+
+// Errorf logs an error
+func (s *StdLogger) Errorf(format string, v ...interface{}) { s.l.Printf("ERROR "+format, v...) }
+
+// NopLogger discards all messages
+type NopLogger struct{}
+
+func (NopLogger) Debugf(format string, v ...interface{}) {}
+func (NopLogger) Infof(format string, v ...interface{})  {}
+func (NopLogger) Warnf(format string, v ...interface{})  {}
+func (NopLogger) Errorf(format string, v ...interface{}) {}
+
+// ThrottledLogger collapses identical log messages emitted within a window
+// into a single line followed by a "(repeated N times)" summary
+type ThrottledLogger struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*throttleEntry
+	output  func(format string, v ...interface{})
+}
+
+type throttleEntry struct {
+	last       time.Time // Time the message was last written
+	suppressed int       // Occurrences dropped since then
+}
+
+// NewThrottledLogger creates a ThrottledLogger with the given dedup window
+func NewThrottledLogger(window time.Duration) *ThrottledLogger {
+	return &ThrottledLogger{
+		window:  window,
+		entries: make(map[string]*throttleEntry),
+		output:  log.Printf,
+	}
+}
+
+// SetOutput routes messages through out, e.g. a Logger's Errorf
+func (t *ThrottledLogger) SetOutput(out func(format string, v ...interface{})) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.output = out
+}
+
+// SetWindow changes the dedup window
+func (t *ThrottledLogger) SetWindow(window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.window = window
+}
+
+// Printf logs a formatted message unless the same message was already
+// written within the window
+func (t *ThrottledLogger) Printf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Drop entries that have gone quiet so the map does not grow unbounded
+	for key, e := range t.entries {
+		if key != msg && now.Sub(e.last) >= t.window {
+			if e.suppressed > 0 {
+				t.output("%s (repeated %d times)", key, e.suppressed)
+			}
+			delete(t.entries, key)
+		}
+	}
+
+	e, exists := t.entries[msg]
+	if exists && now.Sub(e.last) < t.window {
+		e.suppressed++
+		return
+	}
+
+	if exists && e.suppressed > 0 {
+		t.output("%s (repeated %d times)", msg, e.suppressed)
+	}
+	t.output("%s", msg)
+	t.entries[msg] = &throttleEntry{last: now}
+}
+
+// Flush writes the count of every message suppressed since it was last
+// written and forgets all messages, e.g. before shutting down
+func (t *ThrottledLogger) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, e := range t.entries {
+		if e.suppressed > 0 {
+			t.output("%s (repeated %d times)", key, e.suppressed)
+		}
+		delete(t.entries, key)
+	}
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestThrottledLoggerBoundsRepeats(t *testing.T) {
+	tests := []struct {
+		name      string
+		messages  []string
+		wantLines int // Lines written before Flush
+		wantFlush int // Summary lines written by Flush
+	}{
+		{"one message", []string{"a"}, 1, 0},
+		{"identical messages", []string{"a", "a", "a", "a", "a"}, 1, 1},
+		{"two messages repeated", []string{"a", "b", "a", "b", "a"}, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			l := NewThrottledLogger(time.Hour)
+			l.SetOutput(func(format string, v ...interface{}) {
+				lines = append(lines, fmt.Sprintf(format, v...))
+			})
+
+			for _, msg := range tt.messages {
+				l.Printf("%s", msg)
+			}
+			if len(lines) != tt.wantLines {
+				t.Errorf("wrote %v, want %d lines", lines, tt.wantLines)
+			}
+
+			lines = nil
+			l.Flush()
+			if len(lines) != tt.wantFlush {
+				t.Errorf("Flush wrote %v, want %d lines", lines, tt.wantFlush)
+			}
+
+			// Flushed messages are written again right away
+			lines = nil
+			l.Printf("%s", tt.messages[0])
+			if len(lines) != 1 {
+				t.Errorf("after Flush wrote %v, want the message once", lines)
+			}
+		})
+	}
+}
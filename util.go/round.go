@@ -0,0 +1,47 @@
+package util
+
+import (
+	"math"
+	"strconv"
+)
+
+// rateSignificantDigits 是 Bitfinex 接受的利率有效位數
+const rateSignificantDigits = 5
+
+// RoundDownAmount 將金額向下取整到 decimals 位小數，確保不會超過可用餘額
+func RoundDownAmount(v float64, decimals int) float64 {
+	p := math.Pow10(decimals)
+	// 加上微小偏移，避免 150 被浮點誤差表示成 149.99999999 後被多捨去一位
+	return math.Floor(v*p+1e-6) / p
+}
+
+// ToUnits 將金額向下取整為 10^-decimals 的整數單位（例如 decimals 為 2 時即為分），
+// 讓金額加減不會累積浮點誤差
+func ToUnits(v float64, decimals int) int64 {
+	return int64(math.Floor(v*math.Pow10(decimals) + 1e-6))
+}
+
+// FromUnits 將整數單位換回金額
+func FromUnits(units int64, decimals int) float64 {
+	return float64(units) / math.Pow10(decimals)
+}
+
+// FormatAmount 將金額向下取整後格式化為 API 所需的字串
+func FormatAmount(v float64, decimals int) string {
+	return strconv.FormatFloat(RoundDownAmount(v, decimals), 'f', decimals, 64)
+}
+
+// RoundRate 將利率四捨五入到 Bitfinex 接受的有效位數
+func RoundRate(rate float64) float64 {
+	if rate == 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return rate
+	}
+	magnitude := int(math.Floor(math.Log10(math.Abs(rate))))
+	p := math.Pow10(rateSignificantDigits - 1 - magnitude)
+	return math.Round(rate*p) / p
+}
+
+// FormatRate 將利率規範化後格式化為 API 所需的字串
+func FormatRate(rate float64) string {
+	return strconv.FormatFloat(RoundRate(rate), 'f', -1, 64)
+}
@@ -93,3 +93,56 @@ func SafeInt64(v interface{}) (int64, bool) {
 		return 0, false
 	}
 }
+
+// SafeString 安全地將 interface{} 轉換為 string，nil 或型別不符時回傳 false
+func SafeString(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case json.Number:
+		return val.String(), true
+	default:
+		return "", false
+	}
+}
+
+// FieldFloat 安全地取出陣列中指定索引的 float64，索引越界時回傳 false
+func FieldFloat(arr []interface{}, idx int) (float64, bool) {
+	if idx < 0 || idx >= len(arr) {
+		return 0, false
+	}
+	return SafeFloat64(arr[idx])
+}
+
+// FieldInt 安全地取出陣列中指定索引的 int，索引越界時回傳 false
+func FieldInt(arr []interface{}, idx int) (int, bool) {
+	if idx < 0 || idx >= len(arr) {
+		return 0, false
+	}
+	return SafeInt(arr[idx])
+}
+
+// FieldInt64 安全地取出陣列中指定索引的 int64，索引越界時回傳 false
+func FieldInt64(arr []interface{}, idx int) (int64, bool) {
+	if idx < 0 || idx >= len(arr) {
+		return 0, false
+	}
+	return SafeInt64(arr[idx])
+}
+
+// FieldString 安全地取出陣列中指定索引的 string，索引越界或型別不符時回傳 false
+func FieldString(arr []interface{}, idx int) (string, bool) {
+	if idx < 0 || idx >= len(arr) {
+		return "", false
+	}
+	return SafeString(arr[idx])
+}
+
+// FieldBool 安全地取出陣列中指定索引的 bool，索引越界或型別不符時回傳 false
+func FieldBool(arr []interface{}, idx int) (bool, bool) {
+	if idx < 0 || idx >= len(arr) {
+		return false, false
+	}
+	b, ok := arr[idx].(bool)
+	return b, ok
+}